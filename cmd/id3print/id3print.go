@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strings"
 
 	"honnef.co/go/id3"
 )
@@ -30,25 +29,27 @@ func printFile(name string) {
 		return
 	}
 
-	tag, err := id3.NewDecoder(r).Parse()
+	d := id3.NewDecoder(r)
+	tag, err := d.Parse()
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
 
-	for typ, frames := range tag.Frames {
-		if typ == "TXXX" {
-			for _, frame := range frames {
-				frame := frame.(id3.UserTextInformationFrame)
-				fmt.Printf("%s: %s\n", frame.Description, frame.Text)
-			}
-			continue
+	header := d.Header()
+	fmt.Printf("%s, %d bytes, flags: %s\n", header.Version, header.Size, header.Flags)
+
+	for _, frame := range tag.OrderedFrames() {
+		info := fmt.Sprintf("flags: %s, size: %d", frame.Header().Flags(), frame.Size())
+		if frame, ok := frame.(id3.UnsupportedFrame); ok {
+			info += fmt.Sprintf(", data: %d bytes", len(frame.Data))
 		}
-		var vals []string
-		for _, frame := range frames {
-			vals = append(vals, frame.Value())
+
+		if frame, ok := frame.(id3.UserTextInformationFrame); ok {
+			fmt.Printf("%s [%s]: %s\n", frame.Description, info, frame.Text)
+			continue
 		}
-		fmt.Printf("%s: %s\n", typ.String(), strings.Join(vals, ", "))
+		fmt.Printf("%s [%s]: %s\n", frame.ID().String(), info, frame.Value())
 	}
 }
 
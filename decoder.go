@@ -2,9 +2,17 @@ package id3
 
 import (
 	"bytes"
+	"compress/zlib"
+	"context"
 	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
 	"io"
 	"io/ioutil"
+	"math"
+	"strings"
+	"unicode"
 )
 
 type fnFrameReader func(r io.Reader, header FrameHeader, frameSize int) (Frame, error)
@@ -18,38 +26,119 @@ var frameReaders = map[FrameType]fnFrameReader{
 	"APIC": readAPICFrame,
 	"MCDI": readMCDIFrame,
 	"USLT": readUSLTFrame,
+	"RVA2": readRVA2Frame,
+	"ETCO": readETCOFrame,
+	"SYLT": readSYLTFrame,
+	"ASPI": readASPIFrame,
+	"OWNE": readOWNEFrame,
+	"COMR": readCOMRFrame,
+	"POSS": readPOSSFrame,
+	"RBUF": readRBUFFrame,
+	"MLLT": readMLLTFrame,
+	"GRID": readGRIDFrame,
+	"ENCR": readENCRFrame,
+	"LINK": readLINKFrame,
+	"RVRB": readRVRBFrame,
+	"AENC": readAENCFrame,
+	"SIGN": readSIGNFrame,
+	"USER": readUSERFrame,
 }
 
 // TODO support the following frames:
-// - AENC - Audio encryption
-// - ASPI - Audio seek point index
-// - COMR - Commercial frame
-// - ENCR - Encryption method registration
 // - EQU2 - Equalisation (2)
-// - ETCO - Event timing codes
 // - GEOB - General encapsulated object
-// - GRID - Group identification registration
-// - LINK - Linked information
-// - MLLT - MPEG location lookup table
-// - OWNE - Ownership frame
 // - PCNT - Play counter
 // - POPM - Popularimeter
-// - POSS - Position synchronisation frame
-// - RBUF - Recommended buffer size
-// - RVA2 - Relative volume adjustment (2)
-// - RVRB - Reverb
 // - SEEK - Seek frame
-// - SIGN -
-// - SYLT - Synchronised lyric/text
 // - SYTC - Synchronised tempo codes
 
 type Decoder struct {
-	r io.Reader
-	h Header
+	r   io.Reader
+	h   Header
+	ext ExtendedHeader
+	crc hash.Hash32
+
+	// buf is scratch space that is reused between frames.
+	buf []byte
+	// padding is the size of the tag's padding, once it has been
+	// reached.
+	padding int
+	// warnings are problems that didn't stop us from parsing.
+	warnings []error
+	// frames is the number of frame headers that have been read,
+	// including those of dropped and skipped frames.
+	frames int
+
+	// MaxFrames is the maximum number of frames Parse will parse
+	// before returning a LimitExceededError. Frames that are dropped
+	// or skipped count towards the limit. Zero means no limit.
+	MaxFrames int
+	// MaxTagSize is the maximum tag size, in bytes, that
+	// ParseHeader accepts before returning a LimitExceededError.
+	// Zero means no limit.
+	MaxTagSize int
+	// If Upgrade is set, Parse converts frames of older versions to
+	// their ID3v2.4 equivalents, for example TYER, TDAT and TIME to
+	// TDRC. Otherwise, the frames are kept as they were read.
+	// NewDecoder enables it.
+	Upgrade bool
+	// If DedupTextFrames is set, Parse drops text frames that
+	// occur more than once, keeping the first one. See Tag.Dedup.
+	DedupTextFrames bool
+	// If TrimText is set, trailing null bytes and whitespace are
+	// removed from the values of text frames, which some taggers pad
+	// their values with.
+	TrimText bool
+	// If MergeV1 is set, ParseFile fills in frames that are missing
+	// from the ID3v2 tag with the data of the file's ID3v1 tag.
+	MergeV1 bool
+
+	// decryptors are the registered decryptors, keyed by the method
+	// symbols of ENCR frames.
+	decryptors map[byte]func([]byte) ([]byte, error)
 }
 
+// The default limits of decoders returned by NewDecoder.
+const (
+	DefaultMaxFrames  = 10000
+	DefaultMaxTagSize = 64 << 20
+)
+
 func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{r: r}
+	return &Decoder{
+		r:          r,
+		MaxFrames:  DefaultMaxFrames,
+		MaxTagSize: DefaultMaxTagSize,
+		Upgrade:    true,
+	}
+}
+
+// Reset discards the Decoder's state and makes it read from r, so that
+// it can parse another tag. Its limits, options and decryptors are
+// kept.
+func (d *Decoder) Reset(r io.Reader) {
+	*d = Decoder{
+		r:               r,
+		buf:             d.buf,
+		MaxFrames:       d.MaxFrames,
+		MaxTagSize:      d.MaxTagSize,
+		Upgrade:         d.Upgrade,
+		DedupTextFrames: d.DedupTextFrames,
+		TrimText:        d.TrimText,
+		MergeV1:         d.MergeV1,
+		decryptors:      d.decryptors,
+	}
+}
+
+// RegisterDecryptor registers fn for decrypting frames that were
+// encrypted with the given method, which is the method symbol of an
+// ENCR frame. Encrypted frames that have no registered decryptor are
+// returned as UnsupportedFrame.
+func (d *Decoder) RegisterDecryptor(method byte, fn func([]byte) ([]byte, error)) {
+	if d.decryptors == nil {
+		d.decryptors = make(map[byte]func([]byte) ([]byte, error))
+	}
+	d.decryptors[method] = fn
 }
 
 // ParseHeader parses only the ID3 header.
@@ -58,13 +147,62 @@ func (d *Decoder) ParseHeader() (Header, error) {
 	if err != nil {
 		return Header{}, err
 	}
+	if d.MaxTagSize > 0 && header.Size > d.MaxTagSize {
+		return Header{}, LimitExceededError{"tag size", header.Size, d.MaxTagSize}
+	}
 
 	d.h = header
 	d.r = io.LimitReader(d.r, int64(header.Size))
 
+	if header.Flags.ExtendedHeader() {
+		d.ext, err = readExtendedHeader(d.r, header.Version, header.Size)
+		if err != nil {
+			return header, err
+		}
+		header.IsUpdate = d.ext.Update
+		d.h = header
+		if d.ext.HasCRC {
+			// The CRC covers all data following the extended
+			// header.
+			d.crc = crc32.NewIEEE()
+			d.r = io.LimitReader(io.TeeReader(d.r, d.crc), d.remaining())
+		}
+	}
+
 	return header, nil
 }
 
+// Header returns the header parsed by ParseHeader or Parse.
+func (d *Decoder) Header() Header {
+	return d.h
+}
+
+// ExtendedHeader returns the extended header parsed by ParseHeader or
+// Parse. It is the zero value if the tag has no extended header, or
+// if it is a v2.3 tag.
+func (d *Decoder) ExtendedHeader() ExtendedHeader {
+	return d.ext
+}
+
+// Padding returns the size of the padding that followed the last
+// frame of the tag. It is only meaningful after ParseFrame returned
+// io.EOF, or after a successful call to Parse.
+func (d *Decoder) Padding() int {
+	return d.padding
+}
+
+// skipPadding discards the remainder of the tag, which is padding.
+// read is the number of bytes of padding that have already been
+// consumed. It returns io.EOF on success.
+func (d *Decoder) skipPadding(read int) error {
+	d.padding = read + int(d.remaining())
+	_, err := io.Copy(ioutil.Discard, d.r)
+	if err != nil {
+		return err
+	}
+	return io.EOF
+}
+
 func (d *Decoder) remaining() int64 {
 	return d.r.(*io.LimitedReader).N
 }
@@ -83,6 +221,15 @@ func (d *Decoder) remaining() int64 {
 // Parse cannot be called if either ParseHeader or ParseFrame have
 // been called for the current tag.
 func (d *Decoder) Parse() (*Tag, error) {
+	return d.ParseContext(context.Background())
+}
+
+// ParseContext is like Parse, but aborts parsing when ctx gets
+// cancelled, returning ctx.Err(). The returned tag contains all
+// frames that were parsed before the cancellation.
+func (d *Decoder) ParseContext(ctx context.Context) (*Tag, error) {
+	d.r = contextReader{ctx, d.r}
+
 	tag := NewTag()
 	header, err := d.ParseHeader()
 	if err != nil {
@@ -90,16 +237,14 @@ func (d *Decoder) Parse() (*Tag, error) {
 	}
 	tag.Flags = header.Flags
 
-	// FIXME consider moving this to ParseHeader
-	if header.Flags.ExtendedHeader() {
-		return tag, UnimplementedFeatureError{"extended header"}
-	}
-
 	if header.Flags.Unsynchronisation() {
 		return tag, UnimplementedFeatureError{"unsynchronised tag"}
 	}
 
 	for {
+		if err := ctx.Err(); err != nil {
+			return tag, err
+		}
 		frame, err := d.ParseFrame()
 		if err != nil {
 			if err == io.EOF {
@@ -111,11 +256,169 @@ func (d *Decoder) Parse() (*Tag, error) {
 		tag.Frames[frame.ID()] = append(tag.Frames[frame.ID()], frame)
 	}
 
-	if header.Version < 0x0400 {
+	return tag, d.finishTag(tag)
+}
+
+// ParseSelective is like Parse, but only decodes the frames with the
+// given IDs and skips all others without decoding them. This is
+// considerably cheaper than Parse for tags that contain large frames,
+// such as embedded pictures, that the caller isn't interested in.
+//
+// If Upgrade is set and the tag uses an older version, the frames
+// that get upgraded to the requested ones are decoded, too. For
+// example, requesting TDRC decodes TYER, TDAT and TIME.
+func (d *Decoder) ParseSelective(ids ...FrameType) (*Tag, error) {
+	tag := NewTag()
+	header, err := d.ParseHeader()
+	if err != nil {
+		return tag, err
+	}
+	tag.Flags = header.Flags
+
+	wanted := make(map[FrameType]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+		if header.Version < 0x0400 && d.Upgrade {
+			for _, src := range upgradeSources[id] {
+				wanted[src] = true
+			}
+		}
+	}
+
+	if header.Flags.Unsynchronisation() {
+		return tag, UnimplementedFeatureError{"unsynchronised tag"}
+	}
+
+	for {
+		fh, rawFlags, frameSize, err := d.readFrameHeader()
+		err = d.truncated(err)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return tag, err
+		}
+		if !wanted[fh.id] {
+			if _, err := io.CopyN(ioutil.Discard, d.r, int64(frameSize)); err != nil {
+				return tag, d.truncated(err)
+			}
+			continue
+		}
+
+		frame, err := d.parseFrame(fh, rawFlags, frameSize)
+		if err == errDroppedFrame {
+			continue
+		}
+		if err != nil {
+			return tag, d.truncated(err)
+		}
+		tag.Frames[frame.ID()] = append(tag.Frames[frame.ID()], frame)
+	}
+
+	return tag, d.finishTag(tag)
+}
+
+// upgradeSources maps ID3v2.4 frames to the older frames that
+// Tag.upgrade converts to them.
+var upgradeSources = map[FrameType][]FrameType{
+	"TDRC": {"TYER", "TDAT", "TIME"},
+	"TDOR": {"TORY", "XDOR"},
+}
+
+// finishTag applies the Decoder's options to a tag that has been
+// parsed completely and verifies its CRC.
+func (d *Decoder) finishTag(tag *Tag) error {
+	if d.h.Version < 0x0400 && d.Upgrade {
 		tag.upgrade()
 	}
+	if d.DedupTextFrames {
+		tag.Dedup()
+	}
 
-	return tag, nil
+	if d.crc != nil && d.crc.Sum32() != d.ext.CRC {
+		return CRCMismatchError{d.ext.CRC, d.crc.Sum32()}
+	}
+
+	return nil
+}
+
+// contextReader is an io.Reader that fails once its context has
+// been cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// ParseReaderAt parses the tag at the beginning of r. Besides the tag,
+// it returns the offset at which the audio data begins. Unlike with
+// Decoder, no streaming reader is consumed, so r can be used for
+// reading the audio data afterwards.
+func ParseReaderAt(r io.ReaderAt) (*Tag, int64, error) {
+	d := NewDecoder(io.NewSectionReader(r, 0, math.MaxInt64))
+	tag, err := d.Parse()
+	if err != nil {
+		return tag, 0, err
+	}
+
+	return tag, int64(d.h.TotalSize()), nil
+}
+
+// ErrNoTag is returned by FindTag, ScanFile and ParseFile if they
+// couldn't find a tag.
+var ErrNoTag = errors.New("no ID3 tag found")
+
+// FindTag searches the first maxScan bytes of r for the beginning of
+// an ID3 tag and returns its offset and header. This allows finding
+// tags that are preceded by junk data. The tag can then be parsed
+// with a Decoder reading from the returned offset.
+func FindTag(r io.ReaderAt, maxScan int64) (int64, Header, error) {
+	const chunkSize = 4096
+	buf := make([]byte, chunkSize+frameLength)
+	for start := int64(0); start <= maxScan; start += chunkSize {
+		n, err := r.ReadAt(buf, start)
+		if err != nil && err != io.EOF {
+			return 0, Header{}, err
+		}
+
+		b := buf[:n]
+		for i := 0; i < chunkSize && i < len(b) && start+int64(i) <= maxScan; i++ {
+			if b[i] != Magic[0] {
+				continue
+			}
+			if h, ok := plausibleHeader(b[i:]); ok {
+				return start + int64(i), h, nil
+			}
+		}
+
+		if n < len(buf) {
+			break
+		}
+	}
+
+	return 0, Header{}, ErrNoTag
+}
+
+// plausibleHeader reports whether b starts with something that looks
+// like a valid ID3 header.
+func plausibleHeader(b []byte) (Header, bool) {
+	if len(b) < 10 || b[4] == 0xFF {
+		return Header{}, false
+	}
+	for _, c := range b[6:10] {
+		if c >= 0x80 {
+			return Header{}, false
+		}
+	}
+
+	h, err := NewDecoder(bytes.NewReader(b)).readHeader()
+	return h, err == nil
 }
 
 func readBinary(r io.Reader, args ...interface{}) (err error) {
@@ -158,37 +461,34 @@ func (d *Decoder) readHeader() (header Header, err error) {
 	return header, nil
 }
 
-// ParseFrame reads the next ID3 frame. When it reaches padding, it
-// will read and discard all of it and return io.EOF. This should set
-// the reader immediately before the audio data.
-//
-// ParseHeader must be called before calling ParseFrame.
-func (d *Decoder) ParseFrame() (Frame, error) {
+// readFrameHeader reads the header of the next frame. It returns
+// io.EOF once it reaches the padding or the end of the tag. The
+// returned flags have been converted to the v2.4 layout, rawFlags are
+// the flags as stored in the tag.
+func (d *Decoder) readFrameHeader() (header FrameHeader, rawFlags FrameFlags, frameSize int, err error) {
 	if d.remaining() == 0 {
-		return nil, io.EOF
+		return header, 0, 0, io.EOF
+	}
+	if d.remaining() < frameLength {
+		// Not enough room for another frame, so this has to be
+		// padding.
+		return header, 0, 0, d.skipPadding(0)
 	}
 
-	var (
-		headerBytes struct {
-			ID    [4]byte
-			Size  [4]byte
-			Flags [2]byte
-		}
-		header FrameHeader
-	)
+	var headerBytes struct {
+		ID    [4]byte
+		Size  [4]byte
+		Flags [2]byte
+	}
 
-	err := binary.Read(d.r, binary.BigEndian, &headerBytes)
+	err = binary.Read(d.r, binary.BigEndian, &headerBytes)
 	if err != nil {
-		return nil, err
+		return header, 0, 0, err
 	}
 
 	// We're in the padding, discard remaining bytes and return io.EOF
 	if headerBytes.ID == [4]byte{0, 0, 0, 0} {
-		_, err := io.Copy(ioutil.Discard, d.r)
-		if err != nil {
-			return nil, err
-		}
-		return nil, io.EOF
+		return header, 0, 0, d.skipPadding(frameLength)
 	}
 
 	for _, byte := range headerBytes.ID {
@@ -202,38 +502,208 @@ func (d *Decoder) ParseFrame() (Frame, error) {
 			continue
 		}
 
-		return nil, InvalidFrameHeaderError{headerBytes}
+		return header, 0, 0, InvalidFrameHeaderError{headerBytes}
 	}
 
 	header.id = FrameType(headerBytes.ID[:])
-	header.flags = FrameFlags(int16(headerBytes.Flags[0])<<8 | int16(headerBytes.Flags[1]))
-	frameSize := desynchsafeInt(headerBytes.Size)
+	rawFlags = FrameFlags(int16(headerBytes.Flags[0])<<8 | int16(headerBytes.Flags[1]))
+	header.flags = rawFlags
+	if d.h.Version < 0x0400 {
+		header.flags = upgradeFrameFlags(rawFlags)
+	}
+	frameSize = desynchsafeInt(headerBytes.Size)
+	if int64(frameSize) > d.remaining() {
+		// The frame claims to extend past the end of the tag. Don't
+		// trust it, and don't allocate memory for it.
+		return header, 0, 0, InvalidFrameHeaderError{headerBytes}
+	}
+
+	d.frames++
+	if d.MaxFrames > 0 && d.frames > d.MaxFrames {
+		return header, 0, 0, LimitExceededError{"number of frames", d.frames, d.MaxFrames}
+	}
+
+	return header, rawFlags, frameSize, nil
+}
+
+// ParseFrameRaw is like ParseFrame, but doesn't decode the frame and
+// instead returns its undecoded body. This allows recovering data
+// from frames that ParseFrame cannot handle. The flags are in the
+// v2.4 layout, even for older tags.
+//
+// ParseHeader must be called before calling ParseFrameRaw.
+func (d *Decoder) ParseFrameRaw() (id FrameType, flags FrameFlags, body []byte, err error) {
+	header, _, frameSize, err := d.readFrameHeader()
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	body = make([]byte, frameSize)
+	_, err = io.ReadFull(d.r, body)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	return header.id, header.flags, body, nil
+}
 
-	if header.flags.Compressed() {
-		return nil, UnimplementedFeatureError{"compressed frame"}
-		// TODO: Read decompressed size (4 bytes)
+// ParseFrame reads the next ID3 frame. When it reaches padding, it
+// will read and discard all of it and return io.EOF. This should set
+// the reader immediately before the audio data.
+//
+// ParseHeader must be called before calling ParseFrame.
+func (d *Decoder) ParseFrame() (Frame, error) {
+	for {
+		header, rawFlags, frameSize, err := d.readFrameHeader()
+		if err != nil {
+			return nil, d.truncated(err)
+		}
+		frame, err := d.parseFrame(header, rawFlags, frameSize)
+		if err == errDroppedFrame {
+			continue
+		}
+		return frame, d.truncated(err)
 	}
+}
 
-	if header.flags.Encrypted() {
-		return nil, UnimplementedFeatureError{"encrypted frame"}
-		// TODO: Read encryption method (1 byte)
+// truncated returns a TruncatedTagError instead of err if err was
+// caused by the data ending before the end of the tag.
+func (d *Decoder) truncated(err error) error {
+	if err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if n := int(d.remaining()); n > 0 {
+		return TruncatedTagError{Expected: d.h.Size, Available: d.h.Size - n}
 	}
+	return err
+}
 
-	if header.flags.Grouped() {
-		return nil, UnimplementedFeatureError{"grouped frame"}
-		// TODO: Read group identifier (1 byte)
+// parseFrame parses the frame whose header has been read by
+// readFrameHeader.
+func (d *Decoder) parseFrame(header FrameHeader, rawFlags FrameFlags, frameSize int) (Frame, error) {
+	if frameSize == 0 {
+		// Frames must be at least one byte long. An empty frame
+		// is invalid, but doesn't affect any other frames, so we
+		// drop it and continue with the next one.
+		return d.dropFrame(header, "empty frame")
+	}
+
+	if header.flags&formatFlags == 0x0001 {
+		// The frame's data is preceded by a data length indicator,
+		// but not otherwise transformed. Since the data is stored
+		// as is, the length has to match the rest of the frame.
+		if frameSize < 4 {
+			if _, err := io.CopyN(ioutil.Discard, d.r, int64(frameSize)); err != nil {
+				return nil, err
+			}
+			return d.dropFrame(header, "invalid data length indicator")
+		}
+		var length [4]byte
+		if _, err := io.ReadFull(d.r, length[:]); err != nil {
+			return nil, err
+		}
+		frameSize -= 4
+		if desynchsafeInt(length) != frameSize {
+			if _, err := io.CopyN(ioutil.Discard, d.r, int64(frameSize)); err != nil {
+				return nil, err
+			}
+			return d.dropFrame(header, "invalid data length indicator")
+		}
+		header.flags &^= 0x0001
+	}
+
+	// Frames whose data has been transformed by compression,
+	// encryption and the like are kept verbatim, including the
+	// additional bytes in front of the frame's data, unless we can
+	// undo the transformation.
+	if header.flags&formatFlags > 0 {
+		data := make([]byte, frameSize)
+		_, err := io.ReadFull(d.r, data)
+		if err != nil {
+			return nil, err
+		}
+		if d.h.Version < 0x0400 {
+			data = upgradeFrameData(rawFlags, data)
+		}
+
+		if body, ok := d.decodeFrameData(header.flags, data); ok {
+			header.flags &^= formatFlags
+			return d.parseFrameBody(bytes.NewReader(body), header, len(body))
+		}
+
+		return UnsupportedFrame{
+			FrameHeader: header,
+			Data:        data,
+		}, nil
+	}
+
+	return d.parseFrameBody(d.r, header, frameSize)
+}
+
+// minFrameSizes are the sizes of the mandatory fields of frames that
+// need more than the one byte that every frame has to contain.
+var minFrameSizes = map[FrameType]int{
+	"ASPI": 11,
+	"COMM": 4,
+	"LINK": 4,
+	"MLLT": 10,
+	"POSS": 5,
+	"RBUF": 4,
+	"RVRB": 12,
+	"SYLT": 6,
+	"USER": 4,
+	"USLT": 4,
+}
+
+// minFrameSize returns the minimum size of frames of type id.
+func minFrameSize(id FrameType) int {
+	if n, ok := minFrameSizes[id]; ok {
+		return n
+	}
+	return 1
+}
+
+// parseFrameBody parses the frameSize bytes of frame data in r.
+func (d *Decoder) parseFrameBody(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	if frameSize < minFrameSize(header.id) {
+		// The frame is malformed, but doesn't affect any other
+		// frames, so we drop it and continue with the next one.
+		if _, err := io.CopyN(ioutil.Discard, r, int64(frameSize)); err != nil {
+			return nil, err
+		}
+		if frameSize == 0 {
+			return d.dropFrame(header, "empty frame")
+		}
+		return d.dropFrame(header, "frame too short")
 	}
 
 	if header.id[0] == 'T' && header.id != "TXXX" {
 		var encoding Encoding
 		frame := TextInformationFrame{FrameHeader: header}
-		information := make([]byte, frameSize-1)
-		err := readBinary(d.r, &encoding, &information)
+		if cap(d.buf) < frameSize-1 {
+			d.buf = make([]byte, frameSize-1)
+		}
+		// The text gets copied by the conversion to a string, so
+		// it's safe to reuse the buffer.
+		information := d.buf[:frameSize-1]
+		err := readBinary(r, &encoding)
 		if err != nil {
 			return nil, err
 		}
+		_, err = io.ReadFull(r, information)
+		if err != nil {
+			return nil, err
+		}
+		if !encoding.valid() {
+			return d.dropFrame(header, encoding.String())
+		}
+		d.checkEncoding(header, encoding)
 
 		frame.Text = string(encoding.toUTF8(information))
+		frame.Encoding = encoding
+		if d.TrimText {
+			frame.Text = trimText(frame.Text)
+		}
 
 		return frame, nil
 	}
@@ -241,7 +711,7 @@ func (d *Decoder) ParseFrame() (Frame, error) {
 	if header.id[0] == 'W' && header.id != "WXXX" {
 		frame := URLLinkFrame{FrameHeader: header}
 		url := make([]byte, frameSize)
-		_, err = d.r.Read(url)
+		_, err := r.Read(url)
 		if err != nil {
 			return nil, err
 		}
@@ -250,17 +720,185 @@ func (d *Decoder) ParseFrame() (Frame, error) {
 		return frame, nil
 	}
 
+	data := make([]byte, frameSize)
+	_, err := io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+
 	fn, ok := frameReaders[header.id]
 	if !ok {
-		data := make([]byte, frameSize)
-		n, err := d.r.Read(data)
-
 		return UnsupportedFrame{
 			FrameHeader: header,
-			Data:        data[:n],
-		}, err
+			Data:        data,
+		}, nil
+	}
+
+	if encodedFrames[header.id] && !Encoding(data[0]).valid() {
+		return d.dropFrame(header, Encoding(data[0]).String())
+	}
+	if encodedFrames[header.id] {
+		d.checkEncoding(header, Encoding(data[0]))
+	}
+	// The entire frame has been read already, so errors only
+	// affect this frame.
+	frame, err := fn(bytes.NewReader(data), header, frameSize)
+	if err != nil {
+		return d.dropFrame(header, err.Error())
+	}
+	if tf, ok := frame.(UserTextInformationFrame); ok && d.TrimText {
+		tf.Text = trimText(tf.Text)
+		frame = tf
+	}
+	if pf, ok := frame.(PictureFrame); ok && int(pf.PictureType) >= len(PictureTypes) {
+		d.warnings = append(d.warnings, PictureTypeError{header.id, pf.PictureType})
+		pf.PictureType = 0
+		frame = pf
+	}
+	return frame, nil
+}
+
+// trimText removes trailing whitespace from each of the values in s,
+// as well as trailing empty values.
+func trimText(s string) string {
+	values := strings.Split(s, "\x00")
+	for i, v := range values {
+		values[i] = strings.TrimRightFunc(v, unicode.IsSpace)
+	}
+	for len(values) > 1 && values[len(values)-1] == "" {
+		values = values[:len(values)-1]
+	}
+	return strings.Join(values, "\x00")
+}
+
+// decodeFrameData undoes the transformations described by flags,
+// which must be in the ID3v2.4 layout. It reports false if it
+// doesn't support one of the transformations or if the data is
+// invalid.
+func (d *Decoder) decodeFrameData(flags FrameFlags, data []byte) ([]byte, bool) {
+	if flags&(0x0040|0x0002) > 0 {
+		return nil, false
+	}
+
+	var decrypt func([]byte) ([]byte, error)
+	if flags&0x0004 > 0 {
+		if len(data) < 1 {
+			return nil, false
+		}
+		decrypt = d.decryptors[data[0]]
+		if decrypt == nil {
+			return nil, false
+		}
+		data = data[1:]
+	}
+	length := -1
+	if flags&0x0001 > 0 {
+		if len(data) < 4 {
+			return nil, false
+		}
+		length = desynchsafeInt([4]byte{data[0], data[1], data[2], data[3]})
+		data = data[4:]
+	}
+
+	if decrypt != nil {
+		var err error
+		data, err = decrypt(data)
+		if err != nil {
+			return nil, false
+		}
+	}
+	if flags&0x0008 > 0 {
+		// Compressed frames must have a data length indicator,
+		// which also protects us from decompressing too much.
+		if length == -1 || (d.MaxTagSize > 0 && length > d.MaxTagSize) {
+			return nil, false
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, false
+		}
+		data, err = ioutil.ReadAll(io.LimitReader(zr, int64(length)+1))
+		if err != nil {
+			return nil, false
+		}
+	}
+	if length != -1 && length != len(data) {
+		return nil, false
+	}
+	return data, true
+}
+
+// encodedFrames are the frames handled by frameReaders whose data
+// starts with a text encoding.
+var encodedFrames = map[FrameType]bool{
+	"APIC": true,
+	"COMM": true,
+	"COMR": true,
+	"OWNE": true,
+	"SYLT": true,
+	"TXXX": true,
+	"USER": true,
+	"USLT": true,
+	"WXXX": true,
+}
+
+// checkEncoding records a warning if a frame uses UTF-8 in a tag
+// older than ID3v2.4. Such frames are common and decode just fine,
+// so they are kept.
+func (d *Decoder) checkEncoding(header FrameHeader, encoding Encoding) {
+	if encoding == utf8 && d.h.Version < 0x0400 {
+		d.warnings = append(d.warnings, EncodingVersionError{header.id, encoding, d.h.Version})
+	}
+}
+
+// errDroppedFrame is returned by parseFrame if the frame has been
+// dropped, in which case the caller continues with the next frame.
+var errDroppedFrame = errors.New("dropped frame")
+
+// dropFrame records a warning about a frame that couldn't be parsed.
+// The frame's data must have been consumed already.
+func (d *Decoder) dropFrame(header FrameHeader, reason string) (Frame, error) {
+	d.warnings = append(d.warnings, DroppedFrameError{header.id, reason})
+	return nil, errDroppedFrame
+}
+
+// Warnings returns the problems that were encountered while parsing
+// the tag, but didn't prevent parsing it. Currently, these are frames
+// that were dropped because they were invalid.
+func (d *Decoder) Warnings() []error {
+	return d.warnings
+}
+
+// upgradeFrameData reorders the additional bytes in front of the
+// data of an ID3v2.3 frame to match the layout of ID3v2.4. flags are
+// the original ID3v2.3 frame flags.
+func upgradeFrameData(flags FrameFlags, data []byte) []byte {
+	var size, method, group []byte
+	rest := data
+	if flags&0x0080 > 0 {
+		if len(rest) < 4 {
+			return data
+		}
+		// The decompressed size isn't synchsafe in v2.3
+		size = intToBytes(synchsafeInt(int(binary.BigEndian.Uint32(rest))))
+		rest = rest[4:]
+	}
+	if flags&0x0040 > 0 {
+		if len(rest) < 1 {
+			return data
+		}
+		method = rest[:1]
+		rest = rest[1:]
+	}
+	if flags&0x0020 > 0 {
+		if len(rest) < 1 {
+			return data
+		}
+		group = rest[:1]
+		rest = rest[1:]
 	}
-	return fn(d.r, header, frameSize)
+
+	return concat(group, method, size, rest)
 }
 
 func readTXXXFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
@@ -272,10 +910,9 @@ func readTXXXFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error
 	if err != nil {
 		return nil, err
 	}
-	parts := splitNullN(rest, encoding, 2)
-
-	frame.Description = string(encoding.toUTF8(parts[0]))
-	frame.Text = string(encoding.toUTF8(parts[1]))
+	description, text, _ := nextTerminated(rest, encoding)
+	frame.Description = string(encoding.toUTF8(description))
+	frame.Text = string(encoding.toUTF8(text))
 
 	return frame, nil
 }
@@ -290,9 +927,9 @@ func readWXXXFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error
 		return nil, err
 	}
 
-	parts := splitNullN(rest, encoding, 2)
-	frame.Description = string(encoding.toUTF8(parts[0]))
-	frame.URL = string(iso88591ToUTF8(parts[1]))
+	description, url, _ := nextTerminated(rest, encoding)
+	frame.Description = string(encoding.toUTF8(description))
+	frame.URL = string(iso88591ToUTF8(url))
 
 	return frame, nil
 }
@@ -306,9 +943,9 @@ func readUFIDFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error
 		return nil, err
 	}
 
-	parts := bytes.SplitN(rest, []byte{0}, 2)
-	frame.Owner = string(iso88591.toUTF8(parts[0]))
-	frame.Identifier = parts[1]
+	owner, id, _ := nextTerminated(rest, iso88591)
+	frame.Owner = string(iso88591.toUTF8(owner))
+	frame.Identifier = id
 
 	return frame, nil
 }
@@ -327,12 +964,10 @@ func readCOMMFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error
 		return nil, err
 	}
 
-	parts := splitNullN(rest, encoding, 2)
-
+	description, text, _ := nextTerminated(rest, encoding)
 	frame.Language = string(language[:])
-
-	frame.Description = string(encoding.toUTF8(parts[0]))
-	frame.Text = string(encoding.toUTF8(parts[1]))
+	frame.Description = string(encoding.toUTF8(description))
+	frame.Text = string(encoding.toUTF8(text))
 
 	return frame, nil
 }
@@ -345,9 +980,7 @@ func readPRIVFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error
 		return frame, err
 	}
 
-	parts := bytes.SplitN(data, nul, 2)
-	frame.Owner = parts[0]
-	frame.Data = parts[1]
+	frame.Owner, frame.Data, _ = nextTerminated(data, iso88591)
 
 	return frame, nil
 }
@@ -364,13 +997,19 @@ func readAPICFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error
 		return frame, err
 	}
 
-	parts1 := bytes.SplitN(rest, nul, 2)
-	parts2 := splitNullN(parts1[1][1:], encoding, 2)
+	// The MIME type is always ISO-8859-1 and followed by a single
+	// byte for the picture type, so only the description has to be
+	// split according to the frame's encoding.
+	mime, rest, _ := nextTerminated(rest, iso88591)
+	frame.MIMEType = string(iso88591.toUTF8(mime))
+	if len(rest) < 1 {
+		return frame, nil
+	}
+	frame.PictureType, rest = PictureType(rest[0]), rest[1:]
 
-	frame.MIMEType = string(iso88591.toUTF8(parts1[0]))
-	frame.PictureType = PictureType(parts1[1][0])
-	frame.Description = string(encoding.toUTF8(parts2[0]))
-	frame.Data = parts2[1]
+	description, data, _ := nextTerminated(rest, encoding)
+	frame.Description = string(encoding.toUTF8(description))
+	frame.Data = data
 
 	return frame, nil
 }
@@ -396,11 +1035,394 @@ func readUSLTFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error
 		return frame, err
 	}
 
-	parts := splitNullN(rest, encoding, 2)
+	description, lyrics, _ := nextTerminated(rest, encoding)
+	frame.Language = string(language[:])
+	frame.Description = string(encoding.toUTF8(description))
+	frame.Lyrics = string(encoding.toUTF8(lyrics))
+
+	return frame, nil
+}
+
+func readRVA2Frame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := RelativeVolumeAdjustmentFrame{FrameHeader: header}
+	rest := make([]byte, frameSize)
+
+	err := binary.Read(r, binary.BigEndian, rest)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := bytes.SplitN(rest, nul, 2)
+	frame.Identification = string(iso88591.toUTF8(parts[0]))
+	if len(parts) < 2 {
+		return frame, nil
+	}
+
+	data := parts[1]
+	for len(data) >= 4 {
+		adj := VolumeAdjustment{
+			Channel:    ChannelType(data[0]),
+			Adjustment: float64(int16(data[1])<<8|int16(data[2])) / 512,
+		}
+
+		bits := int(data[3])
+		n := (bits + 7) / 8
+		data = data[4:]
+		if n > len(data) {
+			break
+		}
+
+		if bits > 0 && bits <= 64 {
+			var peak uint64
+			for _, b := range data[:n] {
+				peak = peak<<8 | uint64(b)
+			}
+			adj.Peak = float64(peak) / float64(uint64(1)<<uint(bits-1))
+		}
+		data = data[n:]
+
+		frame.Adjustments = append(frame.Adjustments, adj)
+	}
+
+	return frame, nil
+}
+
+func readETCOFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := EventTimingFrame{FrameHeader: header}
+	rest := make([]byte, frameSize)
+
+	err := binary.Read(r, binary.BigEndian, rest)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) == 0 {
+		return frame, nil
+	}
+
+	frame.TimestampFormat = TimestampFormat(rest[0])
+	for data := rest[1:]; len(data) >= 5; data = data[5:] {
+		frame.Events = append(frame.Events, TimingEvent{
+			Type:      data[0],
+			Timestamp: binary.BigEndian.Uint32(data[1:5]),
+		})
+	}
+
+	return frame, nil
+}
+
+func readSYLTFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := SynchronisedLyricsFrame{FrameHeader: header}
+	var (
+		encoding Encoding
+		language [3]byte
+		format   TimestampFormat
+		content  byte
+		rest     []byte
+	)
+	rest = make([]byte, frameSize-6)
+
+	err := readBinary(r, &encoding, &language, &format, &content, &rest)
+	if err != nil {
+		return nil, err
+	}
+
+	frame.Language = string(language[:])
+	frame.TimestampFormat = format
+	frame.ContentType = content
+
+	desc, rest, _ := nextTerminated(rest, encoding)
+	frame.Description = string(encoding.toUTF8(desc))
+
+	for len(rest) > 0 {
+		text, data, ok := nextTerminated(rest, encoding)
+		if !ok || len(data) < 4 {
+			break
+		}
+
+		frame.Lines = append(frame.Lines, SyncedText{
+			Text:      string(encoding.toUTF8(text)),
+			Timestamp: binary.BigEndian.Uint32(data[:4]),
+		})
+		rest = data[4:]
+	}
+
+	return frame, nil
+}
+
+func readASPIFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := AudioSeekPointIndexFrame{FrameHeader: header}
+	var (
+		numPoints uint16
+		rest      []byte
+	)
+	rest = make([]byte, frameSize-11)
+
+	err := readBinary(r, &frame.DataStart, &frame.DataLength, &numPoints, &frame.BitsPerPoint, &rest)
+	if err != nil {
+		return nil, err
+	}
+
+	size := frame.pointSize()
+	for i := 0; i < int(numPoints) && len(rest) >= size; i++ {
+		if size == 1 {
+			frame.Points = append(frame.Points, uint16(rest[0]))
+		} else {
+			frame.Points = append(frame.Points, binary.BigEndian.Uint16(rest))
+		}
+		rest = rest[size:]
+	}
+
+	return frame, nil
+}
+
+func readOWNEFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := OwnershipFrame{FrameHeader: header}
+	var (
+		encoding Encoding
+		rest     []byte
+	)
+	rest = make([]byte, frameSize-1)
+
+	err := readBinary(r, &encoding, &rest)
+	if err != nil {
+		return nil, err
+	}
+
+	price, rest, _ := nextTerminated(rest, iso88591)
+	frame.PricePaid = string(iso88591.toUTF8(price))
+	if len(rest) < 8 {
+		return frame, nil
+	}
+	frame.DatePurchased = string(rest[:8])
+	frame.Seller = string(encoding.toUTF8(rest[8:]))
+
+	return frame, nil
+}
+
+func readCOMRFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := CommercialFrame{FrameHeader: header}
+	var (
+		encoding Encoding
+		rest     []byte
+	)
+	rest = make([]byte, frameSize-1)
+
+	err := readBinary(r, &encoding, &rest)
+	if err != nil {
+		return nil, err
+	}
+
+	price, rest, _ := nextTerminated(rest, iso88591)
+	frame.Price = string(iso88591.toUTF8(price))
+	if len(rest) < 8 {
+		return frame, nil
+	}
+	frame.ValidUntil = string(rest[:8])
+
+	url, rest, _ := nextTerminated(rest[8:], iso88591)
+	frame.ContactURL = string(iso88591.toUTF8(url))
+	if len(rest) < 1 {
+		return frame, nil
+	}
+	frame.ReceivedAs = rest[0]
+
+	seller, rest, _ := nextTerminated(rest[1:], encoding)
+	frame.Seller = string(encoding.toUTF8(seller))
+	description, rest, _ := nextTerminated(rest, encoding)
+	frame.Description = string(encoding.toUTF8(description))
+
+	if len(rest) > 0 {
+		mime, logo, _ := nextTerminated(rest, iso88591)
+		frame.LogoMIMEType = string(iso88591.toUTF8(mime))
+		frame.Logo = logo
+	}
+
+	return frame, nil
+}
+
+func readPOSSFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := PositionSyncFrame{FrameHeader: header}
+	rest := make([]byte, frameSize)
+
+	err := binary.Read(r, binary.BigEndian, rest)
+	if err != nil {
+		return nil, err
+	}
+	frame.TimestampFormat = TimestampFormat(rest[0])
+	frame.Position = binary.BigEndian.Uint32(rest[1:5])
+
+	return frame, nil
+}
+
+func readRBUFFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := RecommendedBufferFrame{FrameHeader: header}
+	rest := make([]byte, frameSize)
+
+	err := binary.Read(r, binary.BigEndian, rest)
+	if err != nil {
+		return nil, err
+	}
+	frame.BufferSize = uint32(rest[0])<<16 | uint32(rest[1])<<8 | uint32(rest[2])
+	frame.EmbeddedInfo = rest[3]&1 > 0
+	if len(rest) >= 8 {
+		frame.NextFlagOffset = binary.BigEndian.Uint32(rest[4:8])
+	}
+
+	return frame, nil
+}
+
+func readMLLTFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := MPEGLookupTableFrame{FrameHeader: header}
+	rest := make([]byte, frameSize)
+
+	err := binary.Read(r, binary.BigEndian, rest)
+	if err != nil {
+		return nil, err
+	}
+	frame.FramesBetweenReference = binary.BigEndian.Uint16(rest[0:2])
+	frame.BytesBetweenReference = uint32(rest[2])<<16 | uint32(rest[3])<<8 | uint32(rest[4])
+	frame.MillisecondsBetweenReference = uint32(rest[5])<<16 | uint32(rest[6])<<8 | uint32(rest[7])
+	frame.BitsForBytesDeviation = rest[8]
+	frame.BitsForMillisecondsDeviation = rest[9]
+
+	bb := int(frame.BitsForBytesDeviation)
+	bm := int(frame.BitsForMillisecondsDeviation)
+	if bb > 32 || bm > 32 || bb+bm == 0 {
+		return nil, errors.New("invalid deviation bit counts")
+	}
+
+	br := bitReader{buf: rest[10:]}
+	for br.remaining() >= bb+bm {
+		frame.References = append(frame.References, MPEGLookupReference{
+			BytesDeviation:        br.read(bb),
+			MillisecondsDeviation: br.read(bm),
+		})
+	}
+
+	return frame, nil
+}
+
+// readRegistration reads the owner, symbol and data shared by GRID
+// and ENCR frames.
+func readRegistration(r io.Reader, frameSize int) (owner string, symbol byte, data []byte, err error) {
+	rest := make([]byte, frameSize)
+	err = binary.Read(r, binary.BigEndian, rest)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	ownerBytes, rest, _ := nextTerminated(rest, iso88591)
+	owner = string(iso88591.toUTF8(ownerBytes))
+	if len(rest) == 0 {
+		return owner, 0, nil, nil
+	}
+
+	return owner, rest[0], rest[1:], nil
+}
+
+func readGRIDFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := GroupRegistrationFrame{FrameHeader: header}
+	var err error
+	frame.Owner, frame.GroupSymbol, frame.Data, err = readRegistration(r, frameSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+func readENCRFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := EncryptionRegistrationFrame{FrameHeader: header}
+	var err error
+	frame.Owner, frame.MethodSymbol, frame.Data, err = readRegistration(r, frameSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+func readLINKFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := LinkFrame{FrameHeader: header}
+	rest := make([]byte, frameSize-4)
+
+	err := readBinary(r, &frame.FrameIdentifier, &rest)
+	if err != nil {
+		return nil, err
+	}
+
+	url, rest, _ := nextTerminated(rest, iso88591)
+	frame.URL = string(iso88591.toUTF8(url))
+	frame.IDAndData = rest
+
+	return frame, nil
+}
+
+func readRVRBFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := ReverbFrame{FrameHeader: header}
+	rest := make([]byte, frameSize-12)
+
+	err := readBinary(r, &frame.Left, &frame.Right,
+		&frame.BouncesLeft, &frame.BouncesRight,
+		&frame.FeedbackLeftLeft, &frame.FeedbackLeftRight,
+		&frame.FeedbackRightRight, &frame.FeedbackRightLeft,
+		&frame.PremixLeftRight, &frame.PremixRightLeft, &rest)
+	if err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+func readAENCFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := AudioEncryptionFrame{FrameHeader: header}
+	rest := make([]byte, frameSize)
+
+	err := binary.Read(r, binary.BigEndian, rest)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, rest, _ := nextTerminated(rest, iso88591)
+	frame.Owner = string(iso88591.toUTF8(owner))
+	if len(rest) < 4 {
+		return frame, nil
+	}
+	frame.PreviewStart = binary.BigEndian.Uint16(rest[0:2])
+	frame.PreviewLength = binary.BigEndian.Uint16(rest[2:4])
+	frame.EncryptionInfo = rest[4:]
+
+	return frame, nil
+}
+
+func readSIGNFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := SignatureFrame{FrameHeader: header}
+	frame.Signature = make([]byte, frameSize-1)
+
+	err := readBinary(r, &frame.GroupSymbol, &frame.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+func readUSERFrame(r io.Reader, header FrameHeader, frameSize int) (Frame, error) {
+	frame := TermsOfUseFrame{FrameHeader: header}
+	var (
+		encoding Encoding
+		language [3]byte
+		rest     []byte
+	)
+	rest = make([]byte, frameSize-4)
+
+	err := readBinary(r, &encoding, &language, &rest)
+	if err != nil {
+		return nil, err
+	}
 
 	frame.Language = string(language[:])
-	frame.Description = string(encoding.toUTF8(parts[0]))
-	frame.Lyrics = string(encoding.toUTF8(parts[1]))
+	frame.Text = string(encoding.toUTF8(rest))
 
 	return frame, nil
 }
@@ -74,7 +74,9 @@ Encodings
 While ID3v2 allows a variety of encodings (ISO-8859-1, UTF-16 and in
 v2.4 also UTF-8), this library only supports writing UTF-8. When
 reading frames with different encodings, they will be converted to
-UTF-8.
+UTF-8. The only exception are text information frames, which can be
+written back in their original encoding by setting the Encoder's
+PreserveEncoding field.
 
 The rationale behind this is that UTF-8 is the encoding assumed by
 most of the Go standard library, and that the other encodings have no
@@ -109,5 +111,11 @@ know how to handle them, you can write your own function to parse and
 modify the content. All unsupported frames will be of type
 UnsupportedFrame.
 
+The same applies to frames that are compressed, encrypted or grouped.
+Their data, including the additional bytes mandated by their flags,
+will be written back verbatim. Unsupported frames that ask to be
+discarded when the audio data changes will be dropped if the
+Encoder's FileAltered field is set.
+
 */
 package id3 // import "honnef.co/go/id3"
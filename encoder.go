@@ -1,21 +1,80 @@
 package id3
 
 import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
 	"time"
 )
 
-func generateHeader(size int) []byte {
+func generateHeader(size int, flags HeaderFlags) []byte {
 	size = synchsafeInt(size)
 
-	// TODO flags
-	return concat(Magic, versionByte, nul, intToBytes(size))
+	return concat(Magic, versionByte, []byte{byte(flags)}, intToBytes(size))
 }
 
+const maxTagSize = 1<<28 - 1
+
+var (
+	// ErrNegativePadding is returned by WriteTag if the Encoder's
+	// Padding is negative.
+	ErrNegativePadding = errors.New("negative padding")
+	// ErrTagTooLarge used to be returned by WriteTag if the tag's
+	// size exceeded the maximum of 256 MB.
+	//
+	// Deprecated: A TagTooLargeError is returned instead.
+	ErrTagTooLarge = errors.New("tag too large")
+	// ErrNotBuffered is returned by Flush if Buffered hasn't been
+	// called.
+	ErrNotBuffered = errors.New("encoder isn't buffered")
+)
+
 type Encoder struct {
 	w io.Writer
 	// The amount of padding that will be added after the last frame.
 	Padding int
+	// FileAltered signals that the audio data has been altered, and
+	// TagAltered that the tag has been altered. Unsupported frames
+	// that ask to be discarded in either case will not be written by
+	// WriteTag. Setting both drops all such frames, which is useful
+	// when it isn't known what was changed. By default, all frames
+	// are kept.
+	FileAltered bool
+	TagAltered  bool
+	// If Restrictions is set, WriteTag declares the restrictions in
+	// an extended header and enforces them, truncating text that is
+	// too long and returning a RestrictionError for violations that
+	// can't be fixed.
+	Restrictions *Restrictions
+	// If WriteCRC is set, WriteTag stores a CRC-32 of the frames
+	// and padding in an extended header.
+	WriteCRC bool
+	// If PreserveEncoding is set, text frames will be written with
+	// the encoding they were read with. Otherwise, all text is
+	// written as UTF-8.
+	PreserveEncoding bool
+	// If VerifySizes is set, WriteTag checks that the size of
+	// every frame matches the size of its encoded data, returning a
+	// FrameSizeError otherwise. This guards against bugs in custom
+	// frame types.
+	VerifySizes bool
+	// If StampSoftware is set, WriteTag records it as the encoding
+	// software (TSSE), unless the tag already specifies one and
+	// OverwriteSoftware isn't set.
+	StampSoftware     string
+	OverwriteSoftware bool
+	// If Compress is set, frames for which it returns true are
+	// written compressed with zlib. This is useful for large frames
+	// such as APIC.
+	Compress func(id FrameType) bool
+
+	// buf holds the frames written in buffered mode, while out is
+	// the actual writer.
+	buf *bytes.Buffer
+	out io.Writer
 }
 
 func NewEncoder(w io.Writer) *Encoder {
@@ -26,12 +85,22 @@ func NewEncoder(w io.Writer) *Encoder {
 }
 
 func (e *Encoder) WriteHeader(size int) error {
-	h := generateHeader(size)
+	if size > maxTagSize {
+		return TagTooLargeError{10 + size, 10 + maxTagSize}
+	}
+	h := generateHeader(size, 0)
 	_, err := e.w.Write(h)
 	return err
 }
 
 func (e *Encoder) WriteFrame(f Frame) error {
+	f = e.prepare(f)
+	if size := f.Size() - frameLength; size > maxTagSize {
+		// The frame's size is stored as a 28 bit synchsafe
+		// integer, too, and the frame couldn't fit in a tag
+		// anyway.
+		return TagTooLargeError{10 + f.Size(), 10 + maxTagSize}
+	}
 	b := f.Header().serialize(f.Size() - frameLength)
 	_, err := e.w.Write(b)
 	if err != nil {
@@ -47,22 +116,280 @@ func (e *Encoder) WritePadding() error {
 	return err
 }
 
+// Buffered makes subsequent calls to WriteFrame collect the frames in
+// memory. Flush then writes them as a complete tag, which spares the
+// caller from computing the tag's size up front.
+func (e *Encoder) Buffered() {
+	if e.buf != nil {
+		return
+	}
+	e.buf = &bytes.Buffer{}
+	e.out = e.w
+	e.w = e.buf
+}
+
+// Flush writes the header, the frames collected since calling
+// Buffered and the padding. Afterwards, the Encoder writes directly
+// again.
+func (e *Encoder) Flush() error {
+	if e.buf == nil {
+		return ErrNotBuffered
+	}
+	buf := e.buf
+	e.w = e.out
+	e.buf = nil
+	e.out = nil
+
+	if e.Padding < 0 {
+		return ErrNegativePadding
+	}
+	if size := buf.Len() + e.Padding; size > maxTagSize {
+		return TagTooLargeError{10 + size, 10 + maxTagSize}
+	}
+	if err := e.WriteHeader(buf.Len() + e.Padding); err != nil {
+		return err
+	}
+	if _, err := buf.WriteTo(e.w); err != nil {
+		return err
+	}
+	return e.WritePadding()
+}
+
 func (e *Encoder) WriteTag(t *Tag) error {
-	t.SetTextFrameTime("TDTG", time.Now().UTC())
-	err := e.WriteHeader(t.Frames.Size() + e.Padding)
+	_, err := e.WriteTagN(t)
+	return err
+}
+
+// WriteTagN is like WriteTag but also returns the number of bytes
+// written, including the header and padding.
+func (e *Encoder) WriteTagN(t *Tag) (int, error) {
+	w := &countingWriter{w: e.w}
+	e.w = w
+	defer func() { e.w = w.w }()
+
+	err := e.writeTag(t, -1)
+	return w.n, err
+}
+
+// WriteTagAndBody writes t, followed by the contents of body, such as
+// the audio data of a file. It returns the total number of bytes
+// written.
+func (e *Encoder) WriteTagAndBody(t *Tag, body io.Reader) (int64, error) {
+	n, err := e.WriteTagN(t)
 	if err != nil {
-		return err
+		return int64(n), err
+	}
+	m, err := io.Copy(e.w, body)
+	return int64(n) + m, err
+}
+
+// WriteTagInto is like WriteTag, but pads the tag so that it is
+// exactly available bytes long, including the header. This allows
+// replacing an existing tag without moving the audio data that
+// follows it. The Encoder's Padding is ignored. If the tag doesn't
+// fit, a TagTooLargeError is returned and nothing is written.
+func (e *Encoder) WriteTagInto(t *Tag, available int) error {
+	return e.writeTag(t, available)
+}
+
+// TagTooLargeError is returned by WriteTagInto if the tag doesn't fit
+// into the available space. WriteTag, Flush, WriteHeader and
+// WriteFrame return it if a size exceeds the maximum of 256 MB that
+// can be stored in a tag. Size and Available include the header.
+type TagTooLargeError struct {
+	Size      int
+	Available int
+}
+
+func (err TagTooLargeError) Error() string {
+	return fmt.Sprintf("tag needs %d bytes, but only %d bytes are available", err.Size, err.Available)
+}
+
+// writeTag writes t. If available is negative, the Encoder's Padding
+// is used, otherwise the tag is padded to be available bytes long.
+func (e *Encoder) writeTag(t *Tag, available int) error {
+	if available < 0 && e.Padding < 0 {
+		return ErrNegativePadding
 	}
 
-	// TODO write important frames first
-	for _, frames := range t.Frames {
-		for _, frame := range frames {
-			err := e.WriteFrame(frame)
-			if err != nil {
+	t.SetTextFrameTime("TDTG", time.Now().UTC())
+	if e.StampSoftware != "" && (e.OverwriteSoftware || !t.HasFrame("TSSE")) {
+		t.SetEncodingSoftware(e.StampSoftware)
+	}
+
+	var frames []Frame
+	for _, frame := range t.OrderedFrames() {
+		if !e.discard(frame) {
+			frames = append(frames, frame)
+		}
+	}
+
+	var (
+		flags HeaderFlags
+		ext   ExtendedHeader
+		extb  []byte
+	)
+	if e.Restrictions != nil {
+		var err error
+		frames, err = e.Restrictions.apply(frames)
+		if err != nil {
+			return err
+		}
+
+		ext.HasRestrictions = true
+		ext.Restrictions = *e.Restrictions
+	}
+
+	size := 0
+	for i, frame := range frames {
+		frame = e.prepare(frame)
+		if e.VerifySizes {
+			if err := checkFrameSize(frame); err != nil {
 				return err
 			}
 		}
+		frames[i] = frame
+		size += frame.Size()
+	}
+	// The CRC doesn't affect the size of the extended header, so we
+	// can determine the amount of padding before computing it.
+	ext.HasCRC = e.WriteCRC
+	if ext != (ExtendedHeader{}) {
+		flags |= 0x40
+		extb = ext.encode()
 	}
 
-	return e.WritePadding()
+	padding := e.Padding
+	if available >= 0 {
+		padding = available - 10 - len(extb) - size
+		if padding < 0 {
+			return TagTooLargeError{10 + len(extb) + size, available}
+		}
+	}
+	if e.Restrictions != nil && 10+len(extb)+size+padding > e.Restrictions.MaxSize() {
+		return RestrictionError{"tag too large"}
+	}
+
+	// The tag size is stored as a 28 bit synchsafe integer. There
+	// is no footer to account for.
+	if len(extb)+size+padding > maxTagSize {
+		return TagTooLargeError{10 + len(extb) + size + padding, 10 + maxTagSize}
+	}
+
+	if e.WriteCRC {
+		crc, err := checksum(frames, padding)
+		if err != nil {
+			return err
+		}
+		ext.CRC = crc
+		extb = ext.encode()
+	}
+
+	_, err := e.w.Write(generateHeader(len(extb)+size+padding, flags))
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(extb)
+	if err != nil {
+		return err
+	}
+
+	for _, frame := range frames {
+		err := e.WriteFrame(frame)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = e.w.Write(make([]byte, padding))
+	return err
+}
+
+// checksum computes the CRC-32 of the frames and the padding, as
+// stored in the extended header.
+func checksum(frames []Frame, padding int) (uint32, error) {
+	h := crc32.NewIEEE()
+	enc := &Encoder{w: h, Padding: padding}
+	for _, frame := range frames {
+		if err := enc.WriteFrame(frame); err != nil {
+			return 0, err
+		}
+	}
+	if err := enc.WritePadding(); err != nil {
+		return 0, err
+	}
+	return h.Sum32(), nil
+}
+
+// checkFrameSize verifies that the size reported by f matches the
+// size of its encoded data.
+func checkFrameSize(f Frame) error {
+	if n := frameLength + len(f.Encode()); n != f.Size() {
+		return FrameSizeError{f.ID(), f.Size(), n}
+	}
+	return nil
+}
+
+// prepare applies the Encoder's settings to f before it is written.
+func (e *Encoder) prepare(f Frame) Frame {
+	if tf, ok := f.(TextInformationFrame); ok && !e.PreserveEncoding {
+		tf.Encoding = utf8
+		f = tf
+	}
+	if e.Compress != nil && e.Compress(f.ID()) {
+		if _, ok := f.(UnsupportedFrame); !ok {
+			f = compressFrame(f)
+		}
+	}
+	return f
+}
+
+// compressFrame returns f with its data compressed. Because the
+// result has to be written verbatim, it is an UnsupportedFrame.
+func compressFrame(f Frame) Frame {
+	data := f.Encode()
+	buf := &bytes.Buffer{}
+	// The data length indicator is mandatory for compressed frames.
+	buf.Write(intToBytes(synchsafeInt(len(data))))
+	w := zlib.NewWriter(buf)
+	// Writes to a bytes.Buffer can't fail.
+	w.Write(data)
+	w.Close()
+
+	header := f.Header()
+	header.flags |= 0x0008 | 0x0001
+	return UnsupportedFrame{
+		FrameHeader: header,
+		Data:        buf.Bytes(),
+	}
+}
+
+// discard reports whether WriteTag should drop f.
+func (e *Encoder) discard(f Frame) bool {
+	switch f.ID() {
+	case "TRDA", "TSIZ":
+		// These frames don't exist in v2.4 and cannot be
+		// upgraded automatically.
+		return true
+	}
+
+	if _, ok := f.(UnsupportedFrame); !ok {
+		return false
+	}
+
+	flags := f.Header().Flags()
+	return (e.FileAltered && !flags.PreserveFileAlteration()) ||
+		(e.TagAltered && !flags.PreserveTagAlteration())
+}
+
+// countingWriter counts the number of bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += n
+	return n, err
 }
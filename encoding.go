@@ -2,7 +2,9 @@ package id3
 
 import (
 	"fmt"
+	"sync"
 	utf16pkg "unicode/utf16"
+	utf8pkg "unicode/utf8"
 )
 
 const (
@@ -35,14 +37,21 @@ func (e Encoding) String() string {
 	}
 }
 
+func (e Encoding) valid() bool {
+	return e <= utf8
+}
+
+// toUTF8 converts b to UTF-8 and strips a trailing null byte. The
+// result may share memory with b.
 func (e Encoding) toUTF8(b []byte) []byte {
 	var ret []byte
 	switch e {
-	case utf16bom, utf16be:
+	case utf16bom:
 		ret = utf16ToUTF8(b)
+	case utf16be:
+		ret = utf16beToUTF8(b)
 	case utf8:
-		ret = make([]byte, len(b))
-		copy(ret, b)
+		ret = b
 	case iso88591:
 		ret = iso88591ToUTF8(b)
 	default:
@@ -56,6 +65,20 @@ func (e Encoding) toUTF8(b []byte) []byte {
 	return ret
 }
 
+// fromUTF8 converts b from UTF-8 to e.
+func (e Encoding) fromUTF8(b []byte) []byte {
+	switch e {
+	case utf16bom:
+		return utf8ToUTF16(b, true)
+	case utf16be:
+		return utf8ToUTF16(b, false)
+	case iso88591:
+		return utf8ToISO88591(b)
+	default:
+		return b
+	}
+}
+
 func (e Encoding) toISO88591(b []byte) []byte {
 	if e != utf8 {
 		panic("Conversion to ISO-8859-1 is only implemented for UTF-8")
@@ -73,32 +96,137 @@ func (e Encoding) terminator() []byte {
 	}
 }
 
+// looksLittleEndian guesses whether BOM-less UTF-16 is little endian.
+// Mostly Latin text has zero high bytes, which come second in little
+// endian code units. If the guess is inconclusive, it returns false.
+func looksLittleEndian(input []byte) bool {
+	var even, odd int
+	for i := 0; i+1 < len(input) && i < 64; i += 2 {
+		if input[i] == 0 {
+			even++
+		}
+		if input[i+1] == 0 {
+			odd++
+		}
+	}
+	return odd > even
+}
+
+// utf16ToUTF8 converts UTF-16 text that should start with a BOM to
+// UTF-8. Text without a BOM is assumed to be big endian, unless it
+// looks like little endian.
 func utf16ToUTF8(input []byte) []byte {
-	// ID3v2 allows UTF-16 in two ways: With a BOM or as Big Endian.
-	// So if we have no Little Endian BOM, it has to be Big Endian
-	// either way.
+	return decodeUTF16(input, true)
+}
+
+// utf16beToUTF8 converts UTF-16BE text to UTF-8. A BOM, if present,
+// takes precedence, but the byte order is never guessed.
+func utf16beToUTF8(input []byte) []byte {
+	return decodeUTF16(input, false)
+}
+
+// decodeUTF16 converts UTF-16 to UTF-8, honouring BOMs. Without a
+// BOM, the text is big endian, unless guess is set and the text
+// looks like little endian.
+func decodeUTF16(input []byte, guess bool) []byte {
+	if len(input) < 2 {
+		// Not even a single code unit
+		return []byte{}
+	}
 	bigEndian := true
-	if input[0] == 0xFF && input[1] == 0xFE {
+	if len(input) >= 2 && input[0] == 0xFF && input[1] == 0xFE {
 		bigEndian = false
 		input = input[2:]
-	} else if input[0] == 0xFE && input[1] == 0xFF {
+	} else if len(input) >= 2 && input[0] == 0xFE && input[1] == 0xFF {
 		input = input[2:]
+	} else if guess && looksLittleEndian(input) {
+		// Some taggers write little endian text without a BOM.
+		bigEndian = false
 	}
 
-	uint16s := make([]uint16, len(input)/2)
+	// Every UTF-16 code unit encodes as at most 3 bytes of UTF-8,
+	// and surrogate pairs as 4 bytes.
+	buf := getBuffer(len(input) / 2 * 3)
+	defer putBuffer(buf)
+	res := *buf
 
-	i := 0
-	for j := 0; j < len(input); j += 2 {
+	var (
+		j    int
+		high rune
+	)
+	for i := 0; i+1 < len(input); i += 2 {
+		var r rune
 		if bigEndian {
-			uint16s[i] = uint16(input[j])<<8 | uint16(input[j+1])
+			r = rune(input[i])<<8 | rune(input[i+1])
 		} else {
-			uint16s[i] = uint16(input[j]) | uint16(input[j+1])<<8
+			r = rune(input[i]) | rune(input[i+1])<<8
 		}
 
-		i++
+		if high != 0 {
+			dec := utf16pkg.DecodeRune(high, r)
+			high = 0
+			if dec != utf8pkg.RuneError {
+				j += utf8pkg.EncodeRune(res[j:], dec)
+				continue
+			}
+			// Not a valid surrogate pair
+			j += utf8pkg.EncodeRune(res[j:], utf8pkg.RuneError)
+		}
+		if r >= 0xD800 && r < 0xDC00 && i+3 < len(input) {
+			high = r
+			continue
+		}
+		j += utf8pkg.EncodeRune(res[j:], r)
+
+		// In frames with multiple values, each value may start
+		// with its own BOM.
+		if r == 0 && i+3 < len(input) {
+			if input[i+2] == 0xFF && input[i+3] == 0xFE {
+				bigEndian = false
+				i += 2
+			} else if input[i+2] == 0xFE && input[i+3] == 0xFF {
+				bigEndian = true
+				i += 2
+			}
+		}
 	}
 
-	return []byte(string(utf16pkg.Decode(uint16s)))
+	// A trailing odd byte is malformed and cannot be decoded. We
+	// ignore it and return what we decoded so far.
+	return copyBuffer(res[:j])
+}
+
+// utf8ToUTF16 converts UTF-8 to UTF-16. If bom is true, the result is
+// little endian and every value, as delimited by null bytes, starts
+// with a BOM. Otherwise, the result is big endian.
+func utf8ToUTF16(input []byte, bom bool) []byte {
+	res := make([]byte, 0, len(input)*2+2)
+	start := true
+	for _, r := range string(input) {
+		if start && bom {
+			res = append(res, 0xFF, 0xFE)
+		}
+		start = r == 0
+
+		var units []uint16
+		if r1, r2 := utf16pkg.EncodeRune(r); r1 != utf8pkg.RuneError {
+			units = []uint16{uint16(r1), uint16(r2)}
+		} else {
+			units = []uint16{uint16(r)}
+		}
+		for _, u := range units {
+			if bom {
+				res = append(res, byte(u), byte(u>>8))
+			} else {
+				res = append(res, byte(u>>8), byte(u))
+			}
+		}
+	}
+	if start && bom {
+		res = append(res, 0xFF, 0xFE)
+	}
+
+	return res
 }
 
 func utf8ToISO88591(input []byte) []byte {
@@ -122,15 +250,23 @@ func utf8ToISO88591(input []byte) []byte {
 	return res[:i]
 }
 
+// iso88591ToUTF8 converts ISO-8859-1 to UTF-8. The result may share
+// memory with the input.
 func iso88591ToUTF8(input []byte) []byte {
 	// - ISO-8859-1 bytes match Unicode code points
 	// - All runes <128 correspond to ASCII, same as in UTF-8
-	// - All runes >128 in ISO-8859-1 encode as 2 bytes in UTF-8
-	res := make([]byte, len(input)*2)
+	// - All runes >=128 in ISO-8859-1 encode as 2 bytes in UTF-8
+	if isASCII(input) {
+		return input
+	}
+
+	buf := getBuffer(len(input) * 2)
+	defer putBuffer(buf)
+	res := *buf
 
 	var j int
 	for _, b := range input {
-		if b <= 128 {
+		if b < 128 {
 			res[j] = b
 			j++
 		} else {
@@ -145,5 +281,42 @@ func iso88591ToUTF8(input []byte) []byte {
 		}
 	}
 
-	return res[:j]
+	return copyBuffer(res[:j])
+}
+
+func isASCII(b []byte) bool {
+	for _, c := range b {
+		if c >= 128 {
+			return false
+		}
+	}
+	return true
+}
+
+// bufferPool holds scratch buffers for the conversion functions.
+// Buffers must never be returned to callers; use copyBuffer to copy
+// results out of them.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new([]byte) },
+}
+
+// getBuffer returns a buffer from the pool with a length of at least
+// n bytes.
+func getBuffer(n int) *[]byte {
+	buf := bufferPool.Get().(*[]byte)
+	if cap(*buf) < n {
+		*buf = make([]byte, n)
+	}
+	*buf = (*buf)[:cap(*buf)]
+	return buf
+}
+
+func putBuffer(buf *[]byte) {
+	bufferPool.Put(buf)
+}
+
+func copyBuffer(b []byte) []byte {
+	res := make([]byte, len(b))
+	copy(res, b)
+	return res
 }
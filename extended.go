@@ -0,0 +1,316 @@
+package id3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Register decoder for checking image restrictions
+	_ "image/png"  // Register decoder for checking image restrictions
+	"io"
+	"io/ioutil"
+	"strings"
+	utf8pkg "unicode/utf8"
+)
+
+// ErrInvalidExtendedHeader is returned when the extended header of a
+// tag is malformed.
+var ErrInvalidExtendedHeader = errors.New("invalid extended header")
+
+// ExtendedHeader is the optional extended header of a v2.4 tag.
+type ExtendedHeader struct {
+	// Update signals that the tag is an update of an earlier tag.
+	Update bool
+	// HasCRC signals that CRC is set.
+	HasCRC bool
+	// CRC is the CRC-32 of the frames and padding.
+	CRC uint32
+	// HasRestrictions signals that Restrictions is set.
+	HasRestrictions bool
+	Restrictions    Restrictions
+}
+
+const (
+	extendedUpdate       = 0x40
+	extendedCRC          = 0x20
+	extendedRestrictions = 0x10
+)
+
+func (h ExtendedHeader) encode() []byte {
+	var (
+		flags byte
+		data  []byte
+	)
+	if h.Update {
+		flags |= extendedUpdate
+		data = append(data, 0)
+	}
+	if h.HasCRC {
+		// The 32 bit CRC is stored as a 35 bit synchsafe integer.
+		flags |= extendedCRC
+		data = append(data, 5,
+			byte(h.CRC>>28),
+			byte(h.CRC>>21&0x7F),
+			byte(h.CRC>>14&0x7F),
+			byte(h.CRC>>7&0x7F),
+			byte(h.CRC&0x7F))
+	}
+	if h.HasRestrictions {
+		flags |= extendedRestrictions
+		data = append(data, 1, byte(h.Restrictions))
+	}
+
+	size := 6 + len(data)
+	return concat(intToBytes(synchsafeInt(size)), []byte{1, flags}, data)
+}
+
+// readExtendedHeader reads the extended header of a tag with the
+// given version. Extended headers of v2.3 tags are skipped. size is
+// the number of bytes left in the tag; extended headers claiming to
+// be larger are invalid.
+func readExtendedHeader(r io.Reader, version Version, size int) (ExtendedHeader, error) {
+	var (
+		h   ExtendedHeader
+		buf [4]byte
+	)
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return h, err
+	}
+
+	if version < 0x0400 {
+		// In v2.3, the size excludes the size field itself and
+		// isn't synchsafe.
+		n := int64(buf[0])<<24 | int64(buf[1])<<16 | int64(buf[2])<<8 | int64(buf[3])
+		if n > int64(size-4) {
+			return h, ErrInvalidExtendedHeader
+		}
+		_, err := io.CopyN(ioutil.Discard, r, n)
+		return h, err
+	}
+
+	n := desynchsafeInt(buf)
+	if n < 6 || n > size {
+		return h, ErrInvalidExtendedHeader
+	}
+	rest := make([]byte, n-4)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return h, err
+	}
+	if rest[0] != 1 {
+		return h, ErrInvalidExtendedHeader
+	}
+	flags := rest[1]
+	data := rest[2:]
+
+	// Each flag that is set is followed by its data, in the order of
+	// the flags.
+	for _, flag := range []byte{extendedUpdate, extendedCRC, extendedRestrictions} {
+		if flags&flag == 0 {
+			continue
+		}
+		if len(data) < 1 || len(data) < 1+int(data[0]) {
+			return h, ErrInvalidExtendedHeader
+		}
+		field := data[1 : 1+int(data[0])]
+		data = data[1+int(data[0]):]
+
+		switch flag {
+		case extendedUpdate:
+			h.Update = true
+		case extendedCRC:
+			if len(field) != 5 {
+				return h, ErrInvalidExtendedHeader
+			}
+			h.HasCRC = true
+			for _, b := range field {
+				h.CRC = h.CRC<<7 | uint32(b&0x7F)
+			}
+		case extendedRestrictions:
+			if len(field) != 1 {
+				return h, ErrInvalidExtendedHeader
+			}
+			h.HasRestrictions = true
+			h.Restrictions = Restrictions(field[0])
+		}
+	}
+
+	return h, nil
+}
+
+// CRCMismatchError is returned by Decoder.Parse if the CRC-32 stored
+// in the extended header doesn't match the tag's data. The tag is
+// still parsed.
+type CRCMismatchError struct {
+	Expected uint32
+	Actual   uint32
+}
+
+func (err CRCMismatchError) Error() string {
+	return fmt.Sprintf("CRC mismatch: expected %08x, got %08x", err.Expected, err.Actual)
+}
+
+// Restrictions describe the restrictions a v2.4 tag declares in its
+// extended header, in the format %ppqrrstt.
+type Restrictions byte
+
+// MaxFrames returns the maximum number of frames in the tag.
+func (r Restrictions) MaxFrames() int {
+	return [...]int{128, 64, 32, 32}[r>>6]
+}
+
+// MaxSize returns the maximum size of the tag in bytes.
+func (r Restrictions) MaxSize() int {
+	return [...]int{1 << 20, 128 << 10, 40 << 10, 4 << 10}[r>>6]
+}
+
+// TextEncoding reports whether text is restricted to ISO-8859-1 and
+// UTF-8.
+func (r Restrictions) TextEncoding() bool {
+	return r&0x20 > 0
+}
+
+// MaxTextLength returns the maximum number of characters in a text
+// field, or 0 if there is no restriction.
+func (r Restrictions) MaxTextLength() int {
+	return [...]int{0, 1024, 128, 30}[r>>3&3]
+}
+
+// ImageEncoding reports whether images are restricted to PNG and
+// JPEG.
+func (r Restrictions) ImageEncoding() bool {
+	return r&0x04 > 0
+}
+
+// MaxImageSize returns the maximum width and height of images, or 0
+// if there is no restriction. If exact is true, images have to be
+// exactly that size.
+func (r Restrictions) MaxImageSize() (size int, exact bool) {
+	switch r & 3 {
+	case 1:
+		return 256, false
+	case 2:
+		return 64, false
+	case 3:
+		return 64, true
+	default:
+		return 0, false
+	}
+}
+
+type RestrictionError struct {
+	Reason string
+}
+
+func (err RestrictionError) Error() string {
+	return "tag violates restrictions: " + err.Reason
+}
+
+// apply enforces the restrictions on frames, converting text to
+// UTF-8 and truncating it where possible and returning an error
+// otherwise. It has to be called before the Encoder prepares the
+// frames, so that it sees all frames in their decoded form.
+func (r Restrictions) apply(frames []Frame) ([]Frame, error) {
+	if len(frames) > r.MaxFrames() {
+		return nil, RestrictionError{"too many frames"}
+	}
+
+	out := make([]Frame, len(frames))
+	for i, frame := range frames {
+		switch frame := frame.(type) {
+		case TextInformationFrame:
+			values := strings.Split(frame.Text, "\x00")
+			for j := range values {
+				values[j] = r.truncate(values[j])
+			}
+			frame.Text = strings.Join(values, "\x00")
+			if r.TextEncoding() && frame.Encoding != iso88591 {
+				frame.Encoding = utf8
+			}
+			out[i] = frame
+		case UserTextInformationFrame:
+			frame.Description = r.truncate(frame.Description)
+			frame.Text = r.truncate(frame.Text)
+			out[i] = frame
+		case UserDefinedURLLinkFrame:
+			frame.Description = r.truncate(frame.Description)
+			out[i] = frame
+		case CommentFrame:
+			frame.Description = r.truncate(frame.Description)
+			frame.Text = r.truncate(frame.Text)
+			out[i] = frame
+		case UnsynchronisedLyricsFrame:
+			frame.Description = r.truncate(frame.Description)
+			frame.Lyrics = r.truncate(frame.Lyrics)
+			out[i] = frame
+		case SynchronisedLyricsFrame:
+			frame.Description = r.truncate(frame.Description)
+			lines := make([]SyncedText, len(frame.Lines))
+			for j, line := range frame.Lines {
+				line.Text = r.truncate(line.Text)
+				lines[j] = line
+			}
+			frame.Lines = lines
+			out[i] = frame
+		case TermsOfUseFrame:
+			frame.Text = r.truncate(frame.Text)
+			out[i] = frame
+		case OwnershipFrame:
+			frame.Seller = r.truncate(frame.Seller)
+			out[i] = frame
+		case CommercialFrame:
+			frame.Seller = r.truncate(frame.Seller)
+			frame.Description = r.truncate(frame.Description)
+			out[i] = frame
+		case PictureFrame:
+			if err := r.checkImage(frame); err != nil {
+				return nil, err
+			}
+			frame.Description = r.truncate(frame.Description)
+			out[i] = frame
+		default:
+			out[i] = frame
+		}
+	}
+
+	return out, nil
+}
+
+func (r Restrictions) truncate(s string) string {
+	max := r.MaxTextLength()
+	if max == 0 || utf8pkg.RuneCountInString(s) <= max {
+		return s
+	}
+
+	n := 0
+	for i := range s {
+		if n == max {
+			return s[:i]
+		}
+		n++
+	}
+	return s
+}
+
+func (r Restrictions) checkImage(frame PictureFrame) error {
+	if frame.MIMEType == "-->" {
+		// A link to an image
+		return nil
+	}
+	if r.ImageEncoding() && frame.MIMEType != "image/png" && frame.MIMEType != "image/jpeg" {
+		return RestrictionError{"image isn't PNG or JPEG"}
+	}
+
+	size, exact := r.MaxImageSize()
+	if size == 0 {
+		return nil
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(frame.Data))
+	if err != nil {
+		return RestrictionError{"couldn't determine image size: " + err.Error()}
+	}
+	if cfg.Width > size || cfg.Height > size || (exact && (cfg.Width != size || cfg.Height != size)) {
+		return RestrictionError{"image too large"}
+	}
+
+	return nil
+}
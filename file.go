@@ -0,0 +1,201 @@
+package id3
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// v1Length is the size of an ID3v1 tag.
+const v1Length = 128
+
+// StripTags removes all ID3v2 tags from the beginning of the file at
+// path, as well as an ID3v1 tag at its end, leaving only the audio
+// data. The file is rewritten via a temporary file, so that it is
+// never left in a partially written state. If the file contains no
+// tags, StripTags doesn't modify it. If a tag claims to extend past
+// the end of the file, StripTags returns a TruncatedTagError and
+// leaves the file untouched.
+func StripTags(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	start, end, err := audioBounds(f, fi.Size())
+	if err != nil {
+		return err
+	}
+	if start == 0 && end == fi.Size() {
+		return nil
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, io.NewSectionReader(f, start, end-start)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(fi.Mode()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	f.Close()
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// audioBounds returns the offsets of the audio data in r, which is
+// size bytes long, skipping any number of leading ID3v2 tags and a
+// trailing ID3v1 tag.
+func audioBounds(r io.ReaderAt, size int64) (start, end int64, err error) {
+	buf := make([]byte, frameLength)
+	for {
+		n, err := r.ReadAt(buf, start)
+		if err != nil && err != io.EOF {
+			return 0, 0, err
+		}
+		h, ok := plausibleHeader(buf[:n])
+		if !ok {
+			break
+		}
+		if start+int64(h.TotalSize()) > size {
+			// The tag claims to extend past the end of the file.
+			// Rather than guessing where the audio data starts,
+			// refuse to touch the file.
+			return 0, 0, TruncatedTagError{Expected: h.Size, Available: int(size-start) - 10}
+		}
+		start += int64(h.TotalSize())
+	}
+
+	end = size
+	if end-start >= v1Length {
+		if _, err := r.ReadAt(buf[:3], end-v1Length); err != nil {
+			return 0, 0, err
+		}
+		if bytes.Equal(buf[:3], []byte("TAG")) {
+			end -= v1Length
+		}
+	}
+
+	return start, end, nil
+}
+
+// ParseFile parses the tags of the file at path. It is a shorthand for
+// NewDecoder(nil).ParseFile(path).
+func ParseFile(path string) (*Tag, error) {
+	return NewDecoder(nil).ParseFile(path)
+}
+
+// ParseFile parses the ID3v2 tag at the beginning of the file at path.
+// If the file has no ID3v2 tag, the ID3v1 tag at its end is used
+// instead. If it has both and MergeV1 is set, the ID3v2 tag takes
+// precedence and the ID3v1 tag only provides frames that the ID3v2 tag
+// lacks. ParseFile returns ErrNoTag if the file has neither tag.
+func (d *Decoder) ParseFile(path string) (*Tag, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	v1, err := readV1Tag(f, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(f)
+	ok, err := Check(r)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !ok {
+		if v1 == nil {
+			return nil, ErrNoTag
+		}
+		return v1, nil
+	}
+
+	d.Reset(r)
+	tag, err := d.Parse()
+	if err != nil {
+		return tag, err
+	}
+	if v1 != nil && d.MergeV1 {
+		for id, frames := range v1.Frames {
+			if !tag.HasFrame(id) {
+				tag.Frames[id] = frames
+			}
+		}
+	}
+
+	return tag, nil
+}
+
+// readV1Tag reads the ID3v1 tag at the end of r, which is size bytes
+// long. It returns nil if there is no such tag.
+func readV1Tag(r io.ReaderAt, size int64) (*Tag, error) {
+	if size < v1Length {
+		return nil, nil
+	}
+	b := make([]byte, v1Length)
+	if _, err := r.ReadAt(b, size-v1Length); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(b[:3], []byte("TAG")) {
+		return nil, nil
+	}
+
+	field := func(b []byte) string {
+		if i := bytes.IndexByte(b, 0); i > -1 {
+			b = b[:i]
+		}
+		return string(iso88591ToUTF8(bytes.TrimRight(b, " ")))
+	}
+	tag := NewTag()
+	set := func(id FrameType, s string) {
+		if s != "" {
+			tag.SetTextFrame(id, s)
+		}
+	}
+	set("TIT2", field(b[3:33]))
+	set("TPE1", field(b[33:63]))
+	set("TALB", field(b[63:93]))
+	set("TDRC", field(b[93:97]))
+
+	comment := b[97:127]
+	if comment[28] == 0 && comment[29] != 0 {
+		// ID3v1.1 stores the track number in the last byte of
+		// the comment.
+		set("TRCK", strconv.Itoa(int(comment[29])))
+		comment = comment[:28]
+	}
+	if s := field(comment); s != "" {
+		tag.SetComments([]Comment{{Language: "XXX", Text: s}})
+	}
+	if genre := int(b[127]); genre < len(Genres) {
+		set("TCON", Genres[genre])
+	}
+
+	return tag, nil
+}
@@ -1,5 +1,16 @@
 package id3
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Register decoder for PictureFrame.Decode
+	"math"
+	"strconv"
+	"strings"
+)
+
 var FrameNames = map[FrameType]string{
 	"AENC": "Audio encryption",
 	"APIC": "Attached picture",
@@ -37,6 +48,7 @@ var FrameNames = map[FrameType]string{
 
 	"TALB": "Album/Movie/Show title",
 	"TBPM": "BPM (beats per minute)",
+	"TCMP": "iTunes compilation flag", // iTunes extension
 	"TCOM": "Composer",
 	"TCON": "Content type",
 	"TCOP": "Copyright message",
@@ -137,12 +149,32 @@ type Frame interface {
 	Header() FrameHeader
 	Value() string
 	Encode() []byte
+	// Size returns the size of the encoded frame, including the
+	// frame header.
 	Size() int
 }
 
+// FramesEqual reports whether two frames are equal. Frames are equal
+// if they have the same ID and flags and their contents encode to the
+// same bytes. In particular, the text encoding a frame was read with
+// doesn't affect equality.
+func FramesEqual(a, b Frame) bool {
+	var e Encoder
+	a, b = e.prepare(a), e.prepare(b)
+	return a.ID() == b.ID() &&
+		a.Header().flags == b.Header().flags &&
+		bytes.Equal(a.Encode(), b.Encode())
+}
+
 type TextInformationFrame struct {
 	FrameHeader
 	Text string
+	// Encoding is the encoding the frame was read with. The frame
+	// is written with the same encoding, except for ISO-8859-1 text
+	// that can't be represented in it, which is written as UTF-8.
+	// Encoders reset the encoding to UTF-8 unless their
+	// PreserveEncoding field is set.
+	Encoding Encoding
 }
 
 type UserTextInformationFrame struct {
@@ -201,6 +233,205 @@ type UnsynchronisedLyricsFrame struct {
 	Lyrics      string
 }
 
+type ChannelType byte
+
+const (
+	OtherChannel ChannelType = iota
+	MasterVolume
+	FrontRight
+	FrontLeft
+	BackRight
+	BackLeft
+	FrontCentre
+	BackCentre
+	Subwoofer
+)
+
+type VolumeAdjustment struct {
+	Channel ChannelType
+	// The volume adjustment in decibels. It is stored with a
+	// precision of 1/512 dB.
+	Adjustment float64
+	// The peak volume relative to full scale, usually in the range
+	// [0, 1]. A peak of zero means that no peak is stored.
+	Peak float64
+}
+
+type RelativeVolumeAdjustmentFrame struct {
+	FrameHeader
+	Identification string
+	Adjustments    []VolumeAdjustment
+}
+
+type TimestampFormat byte
+
+const (
+	MPEGFrames   TimestampFormat = 1
+	Milliseconds TimestampFormat = 2
+)
+
+type TimingEvent struct {
+	Type      byte
+	Timestamp uint32
+}
+
+type EventTimingFrame struct {
+	FrameHeader
+	TimestampFormat TimestampFormat
+	Events          []TimingEvent
+}
+
+type SyncedText struct {
+	Text      string
+	Timestamp uint32
+}
+
+type SynchronisedLyricsFrame struct {
+	FrameHeader
+	Language        string
+	TimestampFormat TimestampFormat
+	ContentType     byte
+	Description     string
+	Lines           []SyncedText
+}
+
+type AudioSeekPointIndexFrame struct {
+	FrameHeader
+	DataStart  uint32
+	DataLength uint32
+	// The number of bits used to store each point, either 8 or 16.
+	BitsPerPoint byte
+	Points       []uint16
+}
+
+type OwnershipFrame struct {
+	FrameHeader
+	// The price paid, consisting of a three letter currency code
+	// followed by the amount, e.g. "EUR9.99".
+	PricePaid string
+	// The date of purchase in the form YYYYMMDD.
+	DatePurchased string
+	Seller        string
+}
+
+type CommercialFrame struct {
+	FrameHeader
+	// One or more prices separated by "/", each consisting of a
+	// three letter currency code followed by the amount, e.g.
+	// "EUR9.99/USD10.99".
+	Price string
+	// The date until which the price is valid, in the form YYYYMMDD.
+	ValidUntil  string
+	ContactURL  string
+	ReceivedAs  byte
+	Seller      string
+	Description string
+	// The seller's logo. It is optional, in which case both
+	// LogoMIMEType and Logo are empty.
+	LogoMIMEType string
+	Logo         []byte
+}
+
+type PositionSyncFrame struct {
+	FrameHeader
+	TimestampFormat TimestampFormat
+	Position        uint32
+}
+
+type RecommendedBufferFrame struct {
+	FrameHeader
+	// The buffer size is stored in 24 bits.
+	BufferSize   uint32
+	EmbeddedInfo bool
+	// The offset to the next tag. It is optional and will only be
+	// written if it isn't zero.
+	NextFlagOffset uint32
+}
+
+type MPEGLookupReference struct {
+	BytesDeviation        uint32
+	MillisecondsDeviation uint32
+}
+
+type MPEGLookupTableFrame struct {
+	FrameHeader
+	FramesBetweenReference uint16
+	// BytesBetweenReference and MillisecondsBetweenReference are
+	// stored in 24 bits.
+	BytesBetweenReference        uint32
+	MillisecondsBetweenReference uint32
+	// The number of bits used to store each deviation, at most 32.
+	BitsForBytesDeviation        byte
+	BitsForMillisecondsDeviation byte
+	References                   []MPEGLookupReference
+}
+
+type GroupRegistrationFrame struct {
+	FrameHeader
+	Owner       string
+	GroupSymbol byte
+	Data        []byte
+}
+
+type EncryptionRegistrationFrame struct {
+	FrameHeader
+	Owner        string
+	MethodSymbol byte
+	Data         []byte
+}
+
+type LinkFrame struct {
+	FrameHeader
+	// The ID of the linked frame
+	FrameIdentifier [4]byte
+	URL             string
+	// Additional data that identifies the linked frame, e.g. the
+	// language and description of a COMM frame.
+	IDAndData []byte
+}
+
+type ReverbFrame struct {
+	FrameHeader
+	// Delays in milliseconds
+	Left  uint16
+	Right uint16
+
+	BouncesLeft        byte
+	BouncesRight       byte
+	FeedbackLeftLeft   byte
+	FeedbackLeftRight  byte
+	FeedbackRightRight byte
+	FeedbackRightLeft  byte
+	PremixLeftRight    byte
+	PremixRightLeft    byte
+}
+
+type AudioEncryptionFrame struct {
+	FrameHeader
+	Owner string
+	// The unencrypted part of the audio, in frames.
+	PreviewStart   uint16
+	PreviewLength  uint16
+	EncryptionInfo []byte
+}
+
+type SignatureFrame struct {
+	FrameHeader
+	GroupSymbol byte
+	Signature   []byte
+}
+
+type TermsOfUseFrame struct {
+	FrameHeader
+	Language string
+	Text     string
+}
+
+// UnsupportedFrame is a frame that this library cannot parse, either
+// because its type is unknown or because its data is compressed,
+// encrypted or otherwise transformed. Data contains the entire frame
+// data, including any additional bytes mandated by the frame's
+// flags.
 type UnsupportedFrame struct {
 	FrameHeader
 	Data []byte
@@ -210,6 +441,12 @@ func (f FrameHeader) ID() FrameType {
 	return f.id
 }
 
+// Flags returns the frame's flags. Flags of ID3v2.3 frames are
+// converted to their ID3v2.4 equivalents.
+func (f FrameHeader) Flags() FrameFlags {
+	return f.flags
+}
+
 func (f FrameHeader) serialize(size int) []byte {
 	out := make([]byte, 10)
 	copy(out, f.id)
@@ -224,19 +461,28 @@ func (f FrameHeader) serialize(size int) []byte {
 }
 
 func (f TextInformationFrame) Size() int {
-	if f.FrameHeader.ID() == "TRDA" {
-		return 0
-	}
-
-	return frameLength + len(f.Text) + 1
+	return frameLength + len(f.Encode())
 }
 
 func (f TextInformationFrame) Encode() []byte {
-	switch f.FrameHeader.ID() {
-	case "TRDA", "TSIZ":
-		return nil
+	enc := f.encoding()
+	return concat([]byte{byte(enc)}, enc.fromUTF8([]byte(f.Text)))
+}
+
+// encoding returns the encoding the frame will be written with.
+func (f TextInformationFrame) encoding() Encoding {
+	switch f.Encoding {
+	case utf16bom, utf16be:
+		return f.Encoding
+	case iso88591:
+		for _, r := range f.Text {
+			if r > 0xFF {
+				return utf8
+			}
+		}
+		return iso88591
 	default:
-		return concat(utf8byte, []byte(f.Text))
+		return utf8
 	}
 }
 
@@ -302,7 +548,7 @@ func (f CommentFrame) Size() int {
 }
 
 func (f CommentFrame) Encode() []byte {
-	return concat(utf8byte, []byte(f.Language), []byte(f.Description), nul, []byte(f.Text))
+	return concat(utf8byte, languageCode(f.Language), []byte(f.Description), nul, []byte(f.Text))
 }
 
 func (f CommentFrame) Value() string {
@@ -341,6 +587,37 @@ func (f PictureFrame) Encode() []byte {
 		[]byte{byte(f.PictureType)}, []byte(f.Description), nul, f.Data)
 }
 
+// Decode decodes the picture. It supports all image formats that
+// have been registered with the image package, which includes PNG,
+// JPEG and GIF. It returns the decoded image and the name of its
+// format.
+func (f PictureFrame) Decode() (image.Image, string, error) {
+	if f.MIMEType == "-->" {
+		return nil, "", errors.New("picture is a link, not image data")
+	}
+	img, format, err := image.Decode(bytes.NewReader(f.Data))
+	if err != nil {
+		return nil, "", fmt.Errorf("couldn't decode picture of type %q: %s", f.MIMEType, err)
+	}
+	return img, format, nil
+}
+
+// Extension returns the file extension, including the leading dot,
+// for the picture's MIME type, or an empty string if the MIME type
+// is unknown.
+func (f PictureFrame) Extension() string {
+	switch strings.ToLower(f.MIMEType) {
+	case "image/jpeg", "image/jpg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	default:
+		return ""
+	}
+}
+
 func (f MusicCDIdentifierFrame) Value() string {
 	return string(f.TOC)
 }
@@ -362,18 +639,606 @@ func (f UnsynchronisedLyricsFrame) Size() int {
 }
 
 func (f UnsynchronisedLyricsFrame) Encode() []byte {
-	return concat(utf8byte, []byte(f.Language), []byte(f.Description), nul, []byte(f.Lyrics))
+	return concat(utf8byte, languageCode(f.Language), []byte(f.Description), nul, []byte(f.Lyrics))
+}
+
+func (f RelativeVolumeAdjustmentFrame) Value() string {
+	return f.Identification
+}
+
+func (f RelativeVolumeAdjustmentFrame) Size() int {
+	size := frameLength + len(utf8.toISO88591([]byte(f.Identification))) + len(nul)
+	for _, adj := range f.Adjustments {
+		size += 4
+		if adj.Peak != 0 {
+			size += 2
+		}
+	}
+
+	return size
+}
+
+func (f RelativeVolumeAdjustmentFrame) Encode() []byte {
+	out := concat(utf8.toISO88591([]byte(f.Identification)), nul)
+	for _, adj := range f.Adjustments {
+		vol := int16(math.Floor(adj.Adjustment*512 + 0.5))
+		out = append(out, byte(adj.Channel), byte(uint16(vol)>>8), byte(vol))
+		if adj.Peak == 0 {
+			out = append(out, 0)
+			continue
+		}
+
+		// Peaks are always written with 16 bits of precision.
+		peak := math.Floor(adj.Peak*32768 + 0.5)
+		if peak > math.MaxUint16 {
+			peak = math.MaxUint16
+		}
+		out = append(out, 16, byte(uint16(peak)>>8), byte(uint16(peak)))
+	}
+
+	return out
 }
 
 func (f UnsupportedFrame) Size() int {
 	return frameLength + len(f.Data)
 }
 
+func (f EventTimingFrame) Value() string {
+	return fmt.Sprintf("%d events", len(f.Events))
+}
+
+func (f EventTimingFrame) Size() int {
+	return frameLength + 1 + 5*len(f.Events)
+}
+
+func (f EventTimingFrame) Encode() []byte {
+	out := make([]byte, 1, 1+5*len(f.Events))
+	out[0] = byte(f.TimestampFormat)
+	for _, ev := range f.Events {
+		out = append(out, ev.Type)
+		out = append(out, intToBytes(int(ev.Timestamp))...)
+	}
+
+	return out
+}
+
+func (f SynchronisedLyricsFrame) Value() string {
+	lines := make([]string, len(f.Lines))
+	for i, line := range f.Lines {
+		lines[i] = line.Text
+	}
+
+	return strings.Join(lines, "")
+}
+
+func (f SynchronisedLyricsFrame) Size() int {
+	size := frameLength + 1 + 3 + 2 + len(f.Description) + len(nul)
+	for _, line := range f.Lines {
+		size += len(line.Text) + len(nul) + 4
+	}
+
+	return size
+}
+
+func (f SynchronisedLyricsFrame) Encode() []byte {
+	out := concat(utf8byte, languageCode(f.Language),
+		[]byte{byte(f.TimestampFormat), f.ContentType}, []byte(f.Description), nul)
+	for _, line := range f.Lines {
+		out = append(out, line.Text...)
+		out = append(out, nul...)
+		out = append(out, intToBytes(int(line.Timestamp))...)
+	}
+
+	return out
+}
+
+func (f AudioSeekPointIndexFrame) Value() string {
+	return fmt.Sprintf("%d points", len(f.Points))
+}
+
+func (f AudioSeekPointIndexFrame) pointSize() int {
+	if f.BitsPerPoint == 8 {
+		return 1
+	}
+
+	return 2
+}
+
+func (f AudioSeekPointIndexFrame) Size() int {
+	return frameLength + 11 + len(f.Points)*f.pointSize()
+}
+
+func (f AudioSeekPointIndexFrame) Encode() []byte {
+	out := concat(intToBytes(int(f.DataStart)), intToBytes(int(f.DataLength)),
+		[]byte{byte(len(f.Points) >> 8), byte(len(f.Points)), byte(f.pointSize() * 8)})
+	for _, p := range f.Points {
+		if f.pointSize() == 1 {
+			out = append(out, byte(p))
+		} else {
+			out = append(out, byte(p>>8), byte(p))
+		}
+	}
+
+	return out
+}
+
+func (f OwnershipFrame) Value() string {
+	return f.Seller
+}
+
+func (f OwnershipFrame) Size() int {
+	return frameLength + 1 + len(utf8.toISO88591([]byte(f.PricePaid))) + len(nul) + 8 + len(f.Seller)
+}
+
+func (f OwnershipFrame) Encode() []byte {
+	return concat(utf8byte, utf8.toISO88591([]byte(f.PricePaid)), nul,
+		dateString(f.DatePurchased), []byte(f.Seller))
+}
+
+func (f CommercialFrame) Value() string {
+	return f.Description
+}
+
+func (f CommercialFrame) hasLogo() bool {
+	return f.LogoMIMEType != "" || len(f.Logo) > 0
+}
+
+func (f CommercialFrame) Size() int {
+	size := frameLength +
+		1 +
+		len(utf8.toISO88591([]byte(f.Price))) +
+		len(nul) +
+		8 +
+		len(utf8.toISO88591([]byte(f.ContactURL))) +
+		len(nul) +
+		1 +
+		len(f.Seller) +
+		len(nul) +
+		len(f.Description) +
+		len(nul)
+	if f.hasLogo() {
+		size += len(utf8.toISO88591([]byte(f.LogoMIMEType))) + len(nul) + len(f.Logo)
+	}
+
+	return size
+}
+
+func (f CommercialFrame) Encode() []byte {
+	out := concat(utf8byte, utf8.toISO88591([]byte(f.Price)), nul,
+		dateString(f.ValidUntil), utf8.toISO88591([]byte(f.ContactURL)), nul,
+		[]byte{f.ReceivedAs}, []byte(f.Seller), nul, []byte(f.Description), nul)
+	if f.hasLogo() {
+		out = concat(out, utf8.toISO88591([]byte(f.LogoMIMEType)), nul, f.Logo)
+	}
+
+	return out
+}
+
+func (f PositionSyncFrame) Value() string {
+	return strconv.FormatUint(uint64(f.Position), 10)
+}
+
+func (f PositionSyncFrame) Size() int {
+	return frameLength + 5
+}
+
+func (f PositionSyncFrame) Encode() []byte {
+	return concat([]byte{byte(f.TimestampFormat)}, intToBytes(int(f.Position)))
+}
+
+func (f RecommendedBufferFrame) Value() string {
+	return strconv.FormatUint(uint64(f.BufferSize), 10)
+}
+
+func (f RecommendedBufferFrame) Size() int {
+	if f.NextFlagOffset != 0 {
+		return frameLength + 8
+	}
+
+	return frameLength + 4
+}
+
+func (f RecommendedBufferFrame) Encode() []byte {
+	var flags byte
+	if f.EmbeddedInfo {
+		flags = 1
+	}
+
+	out := concat(intToBytes(int(f.BufferSize))[1:], []byte{flags})
+	if f.NextFlagOffset != 0 {
+		out = concat(out, intToBytes(int(f.NextFlagOffset)))
+	}
+
+	return out
+}
+
+func (f MPEGLookupTableFrame) Value() string {
+	return fmt.Sprintf("%d references", len(f.References))
+}
+
+func (f MPEGLookupTableFrame) Size() int {
+	bits := len(f.References) * (int(f.BitsForBytesDeviation) + int(f.BitsForMillisecondsDeviation))
+	return frameLength + 10 + (bits+7)/8
+}
+
+func (f MPEGLookupTableFrame) Encode() []byte {
+	out := concat(
+		[]byte{byte(f.FramesBetweenReference >> 8), byte(f.FramesBetweenReference)},
+		intToBytes(int(f.BytesBetweenReference))[1:],
+		intToBytes(int(f.MillisecondsBetweenReference))[1:],
+		[]byte{f.BitsForBytesDeviation, f.BitsForMillisecondsDeviation},
+	)
+
+	w := bitWriter{}
+	for _, ref := range f.References {
+		w.write(ref.BytesDeviation, int(f.BitsForBytesDeviation))
+		w.write(ref.MillisecondsDeviation, int(f.BitsForMillisecondsDeviation))
+	}
+
+	return concat(out, w.buf)
+}
+
+func (f GroupRegistrationFrame) Value() string {
+	return f.Owner
+}
+
+func (f GroupRegistrationFrame) Size() int {
+	return frameLength + len(utf8.toISO88591([]byte(f.Owner))) + len(nul) + 1 + len(f.Data)
+}
+
+func (f GroupRegistrationFrame) Encode() []byte {
+	return concat(utf8.toISO88591([]byte(f.Owner)), nul, []byte{f.GroupSymbol}, f.Data)
+}
+
+func (f EncryptionRegistrationFrame) Value() string {
+	return f.Owner
+}
+
+func (f EncryptionRegistrationFrame) Size() int {
+	return frameLength + len(utf8.toISO88591([]byte(f.Owner))) + len(nul) + 1 + len(f.Data)
+}
+
+func (f EncryptionRegistrationFrame) Encode() []byte {
+	return concat(utf8.toISO88591([]byte(f.Owner)), nul, []byte{f.MethodSymbol}, f.Data)
+}
+
+func (f LinkFrame) Value() string {
+	return f.URL
+}
+
+func (f LinkFrame) Size() int {
+	return frameLength + 4 + len(utf8.toISO88591([]byte(f.URL))) + len(nul) + len(f.IDAndData)
+}
+
+func (f LinkFrame) Encode() []byte {
+	return concat(f.FrameIdentifier[:], utf8.toISO88591([]byte(f.URL)), nul, f.IDAndData)
+}
+
+func (f ReverbFrame) Value() string {
+	return fmt.Sprintf("%d/%d ms", f.Left, f.Right)
+}
+
+func (f ReverbFrame) Size() int {
+	return frameLength + 12
+}
+
+func (f ReverbFrame) Encode() []byte {
+	return []byte{
+		byte(f.Left >> 8), byte(f.Left),
+		byte(f.Right >> 8), byte(f.Right),
+		f.BouncesLeft, f.BouncesRight,
+		f.FeedbackLeftLeft, f.FeedbackLeftRight,
+		f.FeedbackRightRight, f.FeedbackRightLeft,
+		f.PremixLeftRight, f.PremixRightLeft,
+	}
+}
+
+func (f AudioEncryptionFrame) Value() string {
+	return f.Owner
+}
+
+func (f AudioEncryptionFrame) Size() int {
+	return frameLength + len(utf8.toISO88591([]byte(f.Owner))) + len(nul) + 4 + len(f.EncryptionInfo)
+}
+
+func (f AudioEncryptionFrame) Encode() []byte {
+	return concat(utf8.toISO88591([]byte(f.Owner)), nul,
+		[]byte{
+			byte(f.PreviewStart >> 8), byte(f.PreviewStart),
+			byte(f.PreviewLength >> 8), byte(f.PreviewLength),
+		},
+		f.EncryptionInfo)
+}
+
+func (f SignatureFrame) Value() string {
+	return string(f.Signature)
+}
+
+func (f SignatureFrame) Size() int {
+	return frameLength + 1 + len(f.Signature)
+}
+
+func (f SignatureFrame) Encode() []byte {
+	return concat([]byte{f.GroupSymbol}, f.Signature)
+}
+
+func (f TermsOfUseFrame) Value() string {
+	return f.Text
+}
+
+func (f TermsOfUseFrame) Size() int {
+	return frameLength + 4 + len(f.Text)
+}
+
+func (f TermsOfUseFrame) Encode() []byte {
+	return concat(utf8byte, languageCode(f.Language), []byte(f.Text))
+}
+
+// Encode returns the frame's data verbatim. Whether the frame should
+// be written at all is decided by the Encoder.
 func (f UnsupportedFrame) Encode() []byte {
-	// TODO check header if unsupported frame should be dropped or copied verbatim
 	return f.Data
 }
 
 func (f UnsupportedFrame) Value() string {
 	return string(f.Data)
 }
+
+// languageCode returns lang as the 3 byte language code that frames
+// like COMM and USLT expect. Any language that isn't exactly 3 bytes
+// long would corrupt the layout of the frame and is replaced with
+// "XXX", which the specification uses for unknown languages.
+func languageCode(lang string) []byte {
+	if len(lang) != 3 {
+		return []byte("XXX")
+	}
+
+	return []byte(lang)
+}
+
+// dateString returns date as the 8 byte YYYYMMDD date that frames
+// like OWNE and COMR expect. Dates of the wrong length are replaced
+// with "00000000".
+func dateString(date string) []byte {
+	if len(date) != 8 {
+		return []byte("00000000")
+	}
+
+	return []byte(date)
+}
+
+// bitWriter packs values of arbitrary bit widths, most significant
+// bit first.
+type bitWriter struct {
+	buf  []byte
+	nbit int
+}
+
+func (w *bitWriter) write(v uint32, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if w.nbit%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if v&(1<<uint(i)) > 0 {
+			w.buf[len(w.buf)-1] |= 0x80 >> uint(w.nbit%8)
+		}
+		w.nbit++
+	}
+}
+
+// bitReader is the counterpart to bitWriter.
+type bitReader struct {
+	buf  []byte
+	nbit int
+}
+
+func (r *bitReader) remaining() int {
+	return len(r.buf)*8 - r.nbit
+}
+
+func (r *bitReader) read(bits int) uint32 {
+	var v uint32
+	for i := 0; i < bits; i++ {
+		v <<= 1
+		if r.buf[r.nbit/8]&(0x80>>uint(r.nbit%8)) > 0 {
+			v |= 1
+		}
+		r.nbit++
+	}
+
+	return v
+}
+
+// NewTextFrame returns a text information frame. id must be the ID of
+// a text information frame other than TXXX.
+func NewTextFrame(id FrameType, text string) TextInformationFrame {
+	return TextInformationFrame{FrameHeader: FrameHeader{id: id}, Text: text}
+}
+
+// NewUserTextFrame returns a user defined text information frame
+// (TXXX).
+func NewUserTextFrame(desc, text string) UserTextInformationFrame {
+	return UserTextInformationFrame{
+		FrameHeader: FrameHeader{id: "TXXX"},
+		Description: desc,
+		Text:        text,
+	}
+}
+
+// NewURLFrame returns a URL link frame. id must be the ID of a URL
+// link frame other than WXXX.
+func NewURLFrame(id FrameType, url string) URLLinkFrame {
+	return URLLinkFrame{FrameHeader: FrameHeader{id: id}, URL: url}
+}
+
+// NewUserURLFrame returns a user defined URL link frame (WXXX).
+func NewUserURLFrame(desc, url string) UserDefinedURLLinkFrame {
+	return UserDefinedURLLinkFrame{
+		FrameHeader: FrameHeader{id: "WXXX"},
+		Description: desc,
+		URL:         url,
+	}
+}
+
+// NewCommentFrame returns a comment frame (COMM).
+func NewCommentFrame(lang, desc, text string) CommentFrame {
+	return CommentFrame{
+		FrameHeader: FrameHeader{id: "COMM"},
+		Language:    lang,
+		Description: desc,
+		Text:        text,
+	}
+}
+
+// NewLyricsFrame returns an unsynchronised lyrics frame (USLT).
+func NewLyricsFrame(lang, desc, lyrics string) UnsynchronisedLyricsFrame {
+	return UnsynchronisedLyricsFrame{
+		FrameHeader: FrameHeader{id: "USLT"},
+		Language:    lang,
+		Description: desc,
+		Lyrics:      lyrics,
+	}
+}
+
+// NewPictureFrame returns an attached picture frame (APIC).
+func NewPictureFrame(mimeType string, typ PictureType, desc string, data []byte) PictureFrame {
+	return PictureFrame{
+		FrameHeader: FrameHeader{id: "APIC"},
+		MIMEType:    mimeType,
+		PictureType: typ,
+		Description: desc,
+		Data:        data,
+	}
+}
+
+// NewPrivateFrame returns a private frame (PRIV).
+func NewPrivateFrame(owner, data []byte) PrivateFrame {
+	return PrivateFrame{FrameHeader: FrameHeader{id: "PRIV"}, Owner: owner, Data: data}
+}
+
+// NewUniqueFileIdentifierFrame returns a unique file identifier frame
+// (UFID).
+func NewUniqueFileIdentifierFrame(owner string, id []byte) UniqueFileIdentifierFrame {
+	return UniqueFileIdentifierFrame{
+		FrameHeader: FrameHeader{id: "UFID"},
+		Owner:       owner,
+		Identifier:  id,
+	}
+}
+
+// NewTermsOfUseFrame returns a terms of use frame (USER).
+func NewTermsOfUseFrame(lang, text string) TermsOfUseFrame {
+	return TermsOfUseFrame{FrameHeader: FrameHeader{id: "USER"}, Language: lang, Text: text}
+}
+
+// cloner is implemented by frames that reference memory, such as
+// byte slices, that must not be shared between copies. Other frames
+// are copied by value.
+type cloner interface {
+	Clone() Frame
+}
+
+// cloneFrame returns a deep copy of f.
+func cloneFrame(f Frame) Frame {
+	if c, ok := f.(cloner); ok {
+		return c.Clone()
+	}
+	return f
+}
+
+// Clone returns a deep copy of the frame.
+func (f UniqueFileIdentifierFrame) Clone() Frame {
+	f.Identifier = append(f.Identifier[:0:0], f.Identifier...)
+	return f
+}
+
+// Clone returns a deep copy of the frame.
+func (f PrivateFrame) Clone() Frame {
+	f.Owner = append(f.Owner[:0:0], f.Owner...)
+	f.Data = append(f.Data[:0:0], f.Data...)
+	return f
+}
+
+// Clone returns a deep copy of the frame.
+func (f PictureFrame) Clone() Frame {
+	f.Data = append(f.Data[:0:0], f.Data...)
+	return f
+}
+
+// Clone returns a deep copy of the frame.
+func (f MusicCDIdentifierFrame) Clone() Frame {
+	f.TOC = append(f.TOC[:0:0], f.TOC...)
+	return f
+}
+
+// Clone returns a deep copy of the frame.
+func (f RelativeVolumeAdjustmentFrame) Clone() Frame {
+	f.Adjustments = append(f.Adjustments[:0:0], f.Adjustments...)
+	return f
+}
+
+// Clone returns a deep copy of the frame.
+func (f EventTimingFrame) Clone() Frame {
+	f.Events = append(f.Events[:0:0], f.Events...)
+	return f
+}
+
+// Clone returns a deep copy of the frame.
+func (f SynchronisedLyricsFrame) Clone() Frame {
+	f.Lines = append(f.Lines[:0:0], f.Lines...)
+	return f
+}
+
+// Clone returns a deep copy of the frame.
+func (f AudioSeekPointIndexFrame) Clone() Frame {
+	f.Points = append(f.Points[:0:0], f.Points...)
+	return f
+}
+
+// Clone returns a deep copy of the frame.
+func (f CommercialFrame) Clone() Frame {
+	f.Logo = append(f.Logo[:0:0], f.Logo...)
+	return f
+}
+
+// Clone returns a deep copy of the frame.
+func (f MPEGLookupTableFrame) Clone() Frame {
+	f.References = append(f.References[:0:0], f.References...)
+	return f
+}
+
+// Clone returns a deep copy of the frame.
+func (f GroupRegistrationFrame) Clone() Frame {
+	f.Data = append(f.Data[:0:0], f.Data...)
+	return f
+}
+
+// Clone returns a deep copy of the frame.
+func (f EncryptionRegistrationFrame) Clone() Frame {
+	f.Data = append(f.Data[:0:0], f.Data...)
+	return f
+}
+
+// Clone returns a deep copy of the frame.
+func (f LinkFrame) Clone() Frame {
+	f.IDAndData = append(f.IDAndData[:0:0], f.IDAndData...)
+	return f
+}
+
+// Clone returns a deep copy of the frame.
+func (f AudioEncryptionFrame) Clone() Frame {
+	f.EncryptionInfo = append(f.EncryptionInfo[:0:0], f.EncryptionInfo...)
+	return f
+}
+
+// Clone returns a deep copy of the frame.
+func (f SignatureFrame) Clone() Frame {
+	f.Signature = append(f.Signature[:0:0], f.Signature...)
+	return f
+}
+
+// Clone returns a deep copy of the frame.
+func (f UnsupportedFrame) Clone() Frame {
+	f.Data = append(f.Data[:0:0], f.Data...)
+	return f
+}
@@ -1,5 +1,10 @@
 package id3
 
+import (
+	"strconv"
+	"strings"
+)
+
 var Genres = []string{
 	"Blues",
 	"Classic Rock",
@@ -128,3 +133,93 @@ var Genres = []string{
 	"Euro-House",
 	"Dance Hall",
 }
+
+// genreName returns the name of genre, which may be the number of an
+// ID3v1 genre or one of the keywords RX and CR.
+func genreName(genre string) string {
+	switch genre {
+	case "RX":
+		return "Remix"
+	case "CR":
+		return "Cover"
+	}
+	if n, err := strconv.Atoi(genre); err == nil && n >= 0 && n < len(Genres) {
+		return Genres[n]
+	}
+	return genre
+}
+
+// genreKeyword returns the keyword for genre, if there is one, and
+// genre itself otherwise.
+func genreKeyword(genre string) string {
+	switch genre {
+	case "Remix":
+		return "RX"
+	case "Cover":
+		return "CR"
+	}
+	return genre
+}
+
+// splitV23Genres splits an ID3v2.3 content type, such as
+// "(17)(9)Refinement", into the names of its genres. A refinement
+// is kept as a genre of its own.
+func splitV23Genres(s string) []string {
+	var genres []string
+	for strings.HasPrefix(s, "(") && !strings.HasPrefix(s, "((") {
+		end := strings.IndexByte(s, ')')
+		if end == -1 {
+			break
+		}
+		genres = append(genres, genreName(s[1:end]))
+		s = s[end+1:]
+	}
+	// A refinement that starts with a parenthesis has it escaped
+	// by a second one.
+	if strings.HasPrefix(s, "((") {
+		s = s[1:]
+	}
+	if s != "" {
+		genres = append(genres, s)
+	}
+	return genres
+}
+
+// formatV23Genres formats genres as an ID3v2.3 content type, the
+// inverse of splitV23Genres. ID3v1 genres, Remix and Cover are
+// written as references, such as "(17)" or "(RX)", followed by the remaining genres as a refinement.
+// Multiple refinements are separated by slashes, the ID3v2.3
+// separator for multiple values.
+func formatV23Genres(genres []string) string {
+	var refs, refinements []string
+	for _, genre := range genres {
+		if n := genreNumber(genre); n != -1 {
+			refs = append(refs, "("+strconv.Itoa(n)+")")
+			continue
+		}
+		if kw := genreKeyword(genre); kw != genre {
+			refs = append(refs, "("+kw+")")
+			continue
+		}
+		refinements = append(refinements, genre)
+	}
+
+	refinement := strings.Join(refinements, "/")
+	// A refinement that starts with a parenthesis has to be escaped
+	// by a second one.
+	if strings.HasPrefix(refinement, "(") {
+		refinement = "(" + refinement
+	}
+	return strings.Join(refs, "") + refinement
+}
+
+// genreNumber returns the number of the ID3v1 genre called name, or
+// -1 if there is none.
+func genreNumber(name string) int {
+	for i, genre := range Genres {
+		if genre == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -3,6 +3,8 @@ package id3
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -26,7 +28,29 @@ var timeFormats = []string{
 	"2006",
 }
 
-// TODO: ID3v2 extended header
+// Precision describes which components of a timestamp are stored in a
+// frame.
+type Precision int
+
+// The precisions of timestamps, from least to most precise. The zero
+// value signals a missing or invalid timestamp.
+const (
+	PrecisionYear Precision = iota + 1
+	PrecisionMonth
+	PrecisionDay
+	PrecisionHour
+	PrecisionMinute
+	PrecisionSecond
+)
+
+// format returns the layout for timestamps with precision p.
+func (p Precision) format() string {
+	if p < PrecisionYear || p > PrecisionSecond {
+		return TimeFormat
+	}
+	return timeFormats[PrecisionSecond-p]
+}
+
 // TODO: unsynchronisation
 
 type HeaderFlags byte
@@ -64,6 +88,89 @@ func (err InvalidTagHeaderError) Error() string {
 	return fmt.Sprintf("not an ID3v2 header: %q", err.Magic)
 }
 
+type InvalidFrameTypeError struct {
+	Type FrameType
+}
+
+func (err InvalidFrameTypeError) Error() string {
+	return fmt.Sprintf("invalid text frame type: %q", string(err.Type))
+}
+
+// DroppedFrameError describes a frame that was dropped while parsing
+// because it was invalid. It is returned by Decoder.Warnings.
+type DroppedFrameError struct {
+	ID     FrameType
+	Reason string
+}
+
+func (err DroppedFrameError) Error() string {
+	return fmt.Sprintf("dropped invalid frame %s: %s", string(err.ID), err.Reason)
+}
+
+// EncodingVersionError describes a frame that uses a text encoding
+// that doesn't exist in the tag's version, such as UTF-8 in an
+// ID3v2.3 tag. The frame is still decoded. It is returned by
+// Decoder.Warnings.
+type EncodingVersionError struct {
+	ID       FrameType
+	Encoding Encoding
+	Version  Version
+}
+
+func (err EncodingVersionError) Error() string {
+	return fmt.Sprintf("frame %s uses %s, which isn't valid in %s",
+		string(err.ID), err.Encoding, err.Version)
+}
+
+// PictureTypeError describes a picture frame with an unknown picture
+// type. The Decoder replaces the type with 0 (Other). It is returned
+// by Decoder.Warnings.
+type PictureTypeError struct {
+	ID   FrameType
+	Type PictureType
+}
+
+func (err PictureTypeError) Error() string {
+	return fmt.Sprintf("frame %s has unknown picture type %d, using Other",
+		string(err.ID), byte(err.Type))
+}
+
+// TruncatedTagError is returned by the Decoder if the data ends
+// before the end of the tag, as declared by its header. Expected and
+// Available are the sizes of the tag, excluding the header.
+type TruncatedTagError struct {
+	Expected  int
+	Available int
+}
+
+func (err TruncatedTagError) Error() string {
+	return fmt.Sprintf("truncated tag: expected %d bytes, got %d", err.Expected, err.Available)
+}
+
+// LimitExceededError is returned by the Decoder if a tag exceeds one
+// of its limits.
+type LimitExceededError struct {
+	Limit string
+	Value int
+	Max   int
+}
+
+func (err LimitExceededError) Error() string {
+	return fmt.Sprintf("%s of %d exceeds limit of %d", err.Limit, err.Value, err.Max)
+}
+
+// FrameSizeError is returned by the Encoder if the size a frame
+// reports doesn't match the size of its encoded data.
+type FrameSizeError struct {
+	ID       FrameType
+	Reported int
+	Actual   int
+}
+
+func (err FrameSizeError) Error() string {
+	return fmt.Sprintf("frame %s reports size %d, but encodes to %d bytes", string(err.ID), err.Reported, err.Actual)
+}
+
 type UnsupportedVersionError struct {
 	Version Version
 }
@@ -76,6 +183,20 @@ type Header struct {
 	Version Version
 	Flags   HeaderFlags
 	Size    int // The size of the tag (exluding the size of the header)
+	// IsUpdate signals that the tag is an update of an earlier tag,
+	// as declared by its extended header.
+	IsUpdate bool
+}
+
+// TotalSize returns the size of the entire tag, including the header
+// and, in ID3v2.4 tags, the footer. This is the offset of the audio
+// data that follows the tag.
+func (h Header) TotalSize() int {
+	size := 10 + h.Size
+	if h.Version >= 0x0400 && h.Flags.Footer() {
+		size += 10
+	}
+	return size
 }
 
 type Tag struct {
@@ -89,6 +210,14 @@ type Comment struct {
 	Text        string
 }
 
+// InvolvedPerson is an entry in an involved people list (TIPL) or
+// musician credits list (TMCL). For musician credits, Role is the
+// instrument.
+type InvolvedPerson struct {
+	Role string
+	Name string
+}
+
 type Peeker interface {
 	Peek(n int) ([]byte, error)
 }
@@ -102,6 +231,24 @@ func Check(r Peeker) (bool, error) {
 	return bytes.Equal(b, Magic), nil
 }
 
+// CheckReader reports whether r looks like it starts with an ID3 tag.
+// Unlike Check, it works with any reader. The returned reader yields
+// the entire data of r, including the bytes that were read for the
+// check. Inputs that are too short to contain a tag are not an error.
+func CheckReader(r io.Reader) (bool, io.Reader, error) {
+	b := make([]byte, len(Magic))
+	n, err := io.ReadFull(r, b)
+	mr := io.MultiReader(bytes.NewReader(b[:n]), r)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return false, mr, nil
+	}
+	if err != nil {
+		return false, mr, err
+	}
+
+	return bytes.Equal(b, Magic), mr, nil
+}
+
 // NewTag returns an empty tag.
 func NewTag() *Tag {
 	return &Tag{Frames: make(FramesMap)}
@@ -124,8 +271,81 @@ func (p PictureType) String() string {
 	return PictureTypes[p]
 }
 
-// TODO: HeaderFlags.String()
-// TODO: FrameFlags.String()
+// PictureTypeByName returns the picture type with the given name, as
+// listed in PictureTypes. Names are compared case-insensitively.
+func PictureTypeByName(name string) (PictureType, bool) {
+	for i, s := range PictureTypes {
+		if strings.EqualFold(s, name) {
+			return PictureType(i), true
+		}
+	}
+	return 0, false
+}
+
+var headerFlagNames = []struct {
+	flag HeaderFlags
+	name string
+}{
+	{128, "Unsynchronisation"},
+	{64, "ExtendedHeader"},
+	{32, "Experimental"},
+	{16, "Footer"},
+}
+
+var frameFlagNames = []struct {
+	flag FrameFlags
+	name string
+}{
+	{0x4000, "DiscardOnTagAlteration"},
+	{0x2000, "DiscardOnFileAlteration"},
+	{0x0008, "Compressed"},
+	{0x0004, "Encrypted"},
+	{0x0040, "Grouped"},
+	{0x1000, "ReadOnly"},
+	{0x0002, "Unsynchronised"},
+	{0x0001, "DataLengthIndicator"},
+}
+
+// String returns the names of all set flags, separated by "|", or
+// "none" if no flags are set. Undefined flags are rendered as
+// hexadecimal numbers.
+func (f HeaderFlags) String() string {
+	var names []string
+	for _, n := range headerFlagNames {
+		if f&n.flag > 0 {
+			names = append(names, n.name)
+			f &^= n.flag
+		}
+	}
+
+	return flagString(names, uint16(f))
+}
+
+// String returns the names of all set flags, separated by "|", or
+// "none" if no flags are set. Undefined flags are rendered as
+// hexadecimal numbers.
+func (f FrameFlags) String() string {
+	var names []string
+	for _, n := range frameFlagNames {
+		if f&n.flag > 0 {
+			names = append(names, n.name)
+			f &^= n.flag
+		}
+	}
+
+	return flagString(names, uint16(f))
+}
+
+func flagString(names []string, undefined uint16) string {
+	if undefined > 0 {
+		names = append(names, fmt.Sprintf("%#x", undefined))
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+
+	return strings.Join(names, "|")
+}
 
 func (f HeaderFlags) Unsynchronisation() bool {
 	return (f & 128) > 0
@@ -139,6 +359,10 @@ func (f HeaderFlags) Experimental() bool {
 	return (f & 32) > 0
 }
 
+func (f HeaderFlags) Footer() bool {
+	return (f & 16) > 0
+}
+
 func (f HeaderFlags) UndefinedSet() bool {
 	return (f & 31) > 0
 }
@@ -156,15 +380,53 @@ func (f FrameFlags) ReadOnly() bool {
 }
 
 func (f FrameFlags) Compressed() bool {
-	return (f & 128) > 0
+	return (f & 0x0008) > 0
 }
 
 func (f FrameFlags) Encrypted() bool {
-	return (f & 64) > 0
+	return (f & 0x0004) > 0
 }
 
 func (f FrameFlags) Grouped() bool {
-	return (f & 32) > 0
+	return (f & 0x0040) > 0
+}
+
+// formatFlags are the frame flags that describe how a frame's data
+// is stored.
+const formatFlags FrameFlags = 0x004F
+
+// undecodable reports whether f holds data that the Decoder couldn't
+// decode, such as an encrypted or grouped frame. Such frames are
+// written back as they are, but their data is meaningless otherwise.
+func undecodable(f Frame) bool {
+	_, ok := f.(UnsupportedFrame)
+	return ok && f.Header().Flags()&formatFlags != 0
+}
+
+// statusFlags are the frame flags that describe how a frame should
+// be treated, as opposed to how its data is stored. They are kept
+// when a frame gets replaced by a setter.
+const statusFlags FrameFlags = 0x7000
+
+// upgradeFrameFlags converts ID3v2.3 frame flags to their ID3v2.4
+// equivalents.
+func upgradeFrameFlags(f FrameFlags) FrameFlags {
+	// v2.3: %abc00000 %ijk00000
+	// v2.4: %0abc0000 %0h00kmnp
+	out := (f & 0xE000) >> 1
+	if f&0x0080 > 0 {
+		// Compressed frames in v2.3 always carry the decompressed
+		// size, which is the data length indicator in v2.4
+		out |= 0x0008 | 0x0001
+	}
+	if f&0x0040 > 0 {
+		out |= 0x0004
+	}
+	if f&0x0020 > 0 {
+		out |= 0x0040
+	}
+
+	return out
 }
 
 func (v Version) String() string {
@@ -174,45 +436,56 @@ func (v Version) String() string {
 // upgrade upgrades tags from an older version to IDv2.4. It should
 // only be called for files that use an older version.
 func (t *Tag) upgrade() {
-	// Upgrade TYER/TDAT/TIME to TDRC if at least
-	// one of TYER, TDAT or TIME are set.
-	if t.HasFrame("TYER") || t.HasFrame("TDAT") || t.HasFrame("TIME") {
+	// Upgrade TYER/TDAT/TIME to TDRC. TDAT and TIME only refine the
+	// year, so without TYER there is nothing to upgrade.
+	if t.HasFrame("TYER") {
 		year := t.GetTextFrameNumber("TYER")
-		date := t.GetTextFrame("TDAT")
-		tim := t.GetTextFrame("TIME")
-
-		if len(date) != 4 {
-			date = "0101"
-		}
 
-		if len(tim) != 4 {
-			tim = "0000"
+		// Only store as much of the time as the frames specify,
+		// ignoring malformed dates and times, and times without a
+		// date.
+		p := PrecisionYear
+		month, day, hour, minute := 1, 1, 0, 0
+		if d, m, ok := splitDigitPairs(t.GetTextFrame("TDAT")); ok && validDate(year, m, d) {
+			p = PrecisionDay
+			day, month = d, m
+			if h, min, ok := splitDigitPairs(t.GetTextFrame("TIME")); ok && h < 24 && min < 60 {
+				p = PrecisionMinute
+				hour, minute = h, min
+			}
 		}
 
-		day, _ := strconv.Atoi(date[0:2])
-		month, _ := strconv.Atoi(date[2:])
-		hour, _ := strconv.Atoi(date[0:2])
-		minute, _ := strconv.Atoi(date[2:])
-
-		t.SetRecordingTime(time.Date(year, time.Month(month), day, hour, minute, 0, 0, time.UTC))
-		t.RemoveFrames("TYER")
-		t.RemoveFrames("TDAT")
-		t.RemoveFrames("TIME")
+		t.SetRecordingTimeWithPrecision(time.Date(year, time.Month(month), day, hour, minute, 0, 0, time.UTC), p)
 	}
+	// None of TYER, TDAT or TIME exist in ID3v2.4.
+	t.RemoveFrames("TYER")
+	t.RemoveFrames("TDAT")
+	t.RemoveFrames("TIME")
 
 	// Upgrade Original Release Year to Original Release Time
 	if !t.HasFrame("TDOR") {
 		if t.HasFrame("XDOR") {
-			panic("not implemented") // FIXME replace XDOR with TDOR
+			// XDOR is a non-standard ID3v2.3 frame that already
+			// uses the timestamp format of ID3v2.4.
+			t.SetTextFrame("TDOR", t.GetTextFrame("XDOR"))
 		} else if t.HasFrame("TORY") {
 			year := t.GetTextFrameNumber("TORY")
-			t.SetOriginalReleaseTime(time.Date(year, 0, 0, 0, 0, 0, 0, time.UTC))
+			t.SetOriginalReleaseTimeWithPrecision(time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), PrecisionYear)
 		}
 	}
+	// Neither TORY nor XDOR exist in ID3v2.4.
+	t.RemoveFrames("TORY")
+	t.RemoveFrames("XDOR")
 
 	for name := range t.Frames {
 		switch name {
-		case "TLAN", "TCON", "TPE1", "TOPE", "TCOM", "TEXT", "TOLY":
+		case "TCON":
+			var genres []string
+			for _, genre := range strings.Split(t.GetTextFrame(name), "/") {
+				genres = append(genres, splitV23Genres(genre)...)
+			}
+			t.SetGenres(genres)
+		case "TLAN", "TPE1", "TOPE", "TCOM", "TEXT", "TOLY":
 			t.SetTextFrameSlice(name, strings.Split(t.GetTextFrame(name), "/"))
 		}
 	}
@@ -222,6 +495,238 @@ func (t *Tag) upgrade() {
 	// TODO TRDA → TDRL
 }
 
+// splitDigitPairs splits a four digit string, as used by TDAT (DDMM)
+// and TIME (HHMM), into its two halves.
+func splitDigitPairs(s string) (a, b int, ok bool) {
+	if len(s) != 4 {
+		return 0, 0, false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, 0, false
+		}
+	}
+	a = int(s[0]-'0')*10 + int(s[1]-'0')
+	b = int(s[2]-'0')*10 + int(s[3]-'0')
+	return a, b, true
+}
+
+// validDate reports whether day is a valid day of the given month.
+func validDate(year, month, day int) bool {
+	if month < 1 || month > 12 || day < 1 {
+		return false
+	}
+	// time.Date normalizes out-of-range days into the next month.
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC).Day() == day
+}
+
+// String returns a human-readable representation of the tag, with
+// one line per frame. Binary data is summarised by its size.
+func (t *Tag) String() string {
+	ids := make([]string, 0, len(t.Frames))
+	for id := range t.Frames {
+		ids = append(ids, string(id))
+	}
+	sort.Strings(ids)
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s (flags: %s)\n", Version(0x0400), t.Flags)
+	for _, id := range ids {
+		for _, frame := range t.Frames[FrameType(id)] {
+			fmt.Fprintf(buf, "%s (%s): %s\n", id, FrameType(id), frameString(frame))
+		}
+	}
+
+	return buf.String()
+}
+
+// Diff returns the IDs of all frames that differ between t and
+// other, sorted. A frame ID is included if it only exists in one of
+// the tags, if the number of frames differs, or if any pair of frames
+// isn't equal according to FramesEqual.
+func (t *Tag) Diff(other *Tag) []FrameType {
+	var ids []string
+	for id, frames := range t.Frames {
+		if !framesEqual(frames, other.Frames[id]) {
+			ids = append(ids, string(id))
+		}
+	}
+	for id := range other.Frames {
+		if _, ok := t.Frames[id]; !ok && len(other.Frames[id]) > 0 {
+			ids = append(ids, string(id))
+		}
+	}
+	sort.Strings(ids)
+
+	diff := make([]FrameType, len(ids))
+	for i, id := range ids {
+		diff[i] = FrameType(id)
+	}
+	return diff
+}
+
+// repeatableFrames are the frames that may occur more than once in a
+// tag.
+var repeatableFrames = map[FrameType]bool{
+	"AENC": true,
+	"APIC": true,
+	"COMM": true,
+	"COMR": true,
+	"ENCR": true,
+	"EQU2": true,
+	"GEOB": true,
+	"GRID": true,
+	"LINK": true,
+	"POPM": true,
+	"PRIV": true,
+	"RVA2": true,
+	"SIGN": true,
+	"SYLT": true,
+	"TXXX": true,
+	"UFID": true,
+	"USER": true,
+	"USLT": true,
+	"WCOM": true,
+	"WOAR": true,
+	"WXXX": true,
+}
+
+// Merge copies the frames of other into t. The frames are deep
+// copies, so other can be modified afterwards. Frames that may only occur
+// once are copied if t doesn't have them yet, or if overwrite is true.
+// Frames that may occur multiple times are added to the existing
+// ones, unless t already has an equal frame. User defined text and
+// URL frames are matched by their descriptions, following the same
+// rules as frames that may only occur once.
+func (t *Tag) Merge(other *Tag, overwrite bool) {
+	for id, frames := range other.Frames {
+		if len(frames) == 0 {
+			continue
+		}
+		if !repeatableFrames[id] {
+			if overwrite || len(t.Frames[id]) == 0 {
+				t.Frames[id] = cloneFrames(frames)
+			}
+			continue
+		}
+
+	outer:
+		for _, frame := range frames {
+			desc, hasDesc := userFrameDescription(frame)
+			for i, local := range t.Frames[id] {
+				if hasDesc {
+					if localDesc, _ := userFrameDescription(local); localDesc == desc {
+						if overwrite {
+							t.Frames[id][i] = cloneFrame(frame)
+						}
+						continue outer
+					}
+				} else if FramesEqual(local, frame) {
+					continue outer
+				}
+			}
+			t.Frames[id] = append(t.Frames[id], cloneFrame(frame))
+		}
+	}
+}
+
+// Dedup removes duplicate text frames, which the specification
+// doesn't allow, keeping the first one. User defined text frames are
+// duplicates if they have the same description.
+func (t *Tag) Dedup() {
+	for id, frames := range t.Frames {
+		if id[0] != 'T' || len(frames) < 2 {
+			continue
+		}
+		if id != "TXXX" {
+			t.Frames[id] = frames[:1]
+			continue
+		}
+
+		var kept []Frame
+		seen := make(map[string]bool)
+		for _, frame := range frames {
+			desc, _ := userFrameDescription(frame)
+			if seen[desc] {
+				continue
+			}
+			seen[desc] = true
+			kept = append(kept, frame)
+		}
+		t.Frames[id] = kept
+	}
+}
+
+// CopyFrom replaces the flags and frames of t with deep copies of
+// those of src, so that modifying one tag doesn't affect the other.
+func (t *Tag) CopyFrom(src *Tag) {
+	t.Flags = src.Flags
+	t.Frames = make(FramesMap, len(src.Frames))
+	for id, frames := range src.Frames {
+		t.Frames[id] = cloneFrames(frames)
+	}
+}
+
+func cloneFrames(frames []Frame) []Frame {
+	out := make([]Frame, len(frames))
+	for i, frame := range frames {
+		out[i] = cloneFrame(frame)
+	}
+	return out
+}
+
+func userFrameDescription(frame Frame) (string, bool) {
+	switch frame := frame.(type) {
+	case UserTextInformationFrame:
+		return frame.Description, true
+	case UserDefinedURLLinkFrame:
+		return frame.Description, true
+	default:
+		return "", false
+	}
+}
+
+func framesEqual(a, b []Frame) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !FramesEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func frameString(frame Frame) string {
+	switch frame := frame.(type) {
+	case TextInformationFrame:
+		return strings.Replace(frame.Text, "\x00", " / ", -1)
+	case UserTextInformationFrame:
+		return frame.Description + ": " + frame.Text
+	case UserDefinedURLLinkFrame:
+		return frame.Description + ": " + frame.URL
+	case CommentFrame:
+		return frame.Language + "/" + frame.Description + ": " + frame.Text
+	case UnsynchronisedLyricsFrame:
+		return frame.Language + "/" + frame.Description + ": " + frame.Lyrics
+	case PictureFrame:
+		return fmt.Sprintf("%s, %s, <%d bytes>", frame.PictureType, frame.MIMEType, len(frame.Data))
+	case UniqueFileIdentifierFrame:
+		return fmt.Sprintf("%s: <%d bytes>", frame.Owner, len(frame.Identifier))
+	case PrivateFrame:
+		return fmt.Sprintf("%s: <%d bytes>", frame.Owner, len(frame.Data))
+	case MusicCDIdentifierFrame:
+		return fmt.Sprintf("<%d bytes>", len(frame.TOC))
+	case SignatureFrame:
+		return fmt.Sprintf("<%d bytes>", len(frame.Signature))
+	case UnsupportedFrame:
+		return fmt.Sprintf("<%d bytes>", len(frame.Data))
+	default:
+		return frame.Value()
+	}
+}
+
 // Clear removes all tags from the file.
 func (t *Tag) Clear() {
 	t.Frames = make(FramesMap)
@@ -231,6 +736,79 @@ func (t *Tag) RemoveFrames(name FrameType) {
 	delete(t.Frames, name)
 }
 
+// FilterFrames returns all frames for which pred returns true. The
+// frames are sorted by their IDs; frames with the same ID keep their
+// order.
+func (t *Tag) FilterFrames(pred func(Frame) bool) []Frame {
+	ids := make([]string, 0, len(t.Frames))
+	for id := range t.Frames {
+		ids = append(ids, string(id))
+	}
+	sort.Strings(ids)
+
+	var out []Frame
+	for _, id := range ids {
+		for _, frame := range t.Frames[FrameType(id)] {
+			if pred(frame) {
+				out = append(out, frame)
+			}
+		}
+	}
+	return out
+}
+
+// importantFrames are the frames that the specification recommends
+// to be written as early as possible, in order.
+var importantFrames = []FrameType{"UFID", "TIT2", "MCDI", "TRCK"}
+
+// OrderedFrames returns all frames in a stable order: the frames
+// that the specification recommends to come first, followed by the
+// remaining text frames and finally all other frames, each sorted by
+// their IDs. Frames with the same ID keep their order.
+func (t *Tag) OrderedFrames() []Frame {
+	rank := func(id FrameType) int {
+		for i, important := range importantFrames {
+			if id == important {
+				return i
+			}
+		}
+		if id[0] == 'T' {
+			return len(importantFrames)
+		}
+		return len(importantFrames) + 1
+	}
+
+	ids := make([]FrameType, 0, len(t.Frames))
+	for id := range t.Frames {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		ri, rj := rank(ids[i]), rank(ids[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return ids[i] < ids[j]
+	})
+
+	var out []Frame
+	for _, id := range ids {
+		out = append(out, t.Frames[id]...)
+	}
+	return out
+}
+
+// IsTextFrame reports whether f is a text information frame,
+// including user defined text frames (TXXX).
+func IsTextFrame(f Frame) bool {
+	return f.ID()[0] == 'T'
+}
+
+// IsURLFrame reports whether f is a URL link frame, including user
+// defined URL frames (WXXX).
+func IsURLFrame(f Frame) bool {
+	return f.ID()[0] == 'W'
+}
+
 // Validate checks whether the tags are conforming to the
 // specification.
 //
@@ -248,22 +826,190 @@ func (t *Tag) RemoveFrames(name FrameType) {
 // getter/setter methods were used the generated tags should always be
 // valid.
 func (t *Tag) Validate() error {
-	// TODO consider returning a list of errors, one per invalid frame,
-	// specifying the reason
+	var problems []Problem
+	for _, p := range t.problems() {
+		problems = append(problems, p.Problem)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return ValidationError{problems}
+}
+
+// Sanitize will remove all frames that aren't valid. Check the
+// documentation of (*Tag).Validate() to see what "valid" means.
+// Frames that only caused warnings will be kept. Language codes are
+// normalized with NormalizeLanguage first.
+func (t *Tag) Sanitize() {
+	t.normalizeLanguages()
+	drop := make(map[FrameType]map[int]bool)
+	for _, p := range t.problems() {
+		if p.Warning {
+			continue
+		}
+		if p.index == -1 {
+			delete(t.Frames, p.Frame)
+			continue
+		}
+		if drop[p.Frame] == nil {
+			drop[p.Frame] = make(map[int]bool)
+		}
+		drop[p.Frame][p.index] = true
+	}
+
+	for id, indices := range drop {
+		frames, ok := t.Frames[id]
+		if !ok {
+			continue
+		}
+		var kept []Frame
+		for i, frame := range frames {
+			if !indices[i] {
+				kept = append(kept, frame)
+			}
+		}
+		t.Frames[id] = kept
+	}
+}
 
-	panic("not implemented") // FIXME
+// ValidationError is returned by Validate and lists all problems that
+// were found.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (err ValidationError) Error() string {
+	msgs := make([]string, len(err.Problems))
+	for i, p := range err.Problems {
+		msgs[i] = p.String()
+	}
+	return "invalid tag: " + strings.Join(msgs, "; ")
+}
+
+// Problem describes a single problem found by Validate.
+type Problem struct {
+	Frame  FrameType
+	Reason string
+	// Warning signals that the frame is valid according to the
+	// specification, but likely to cause problems with players.
+	Warning bool
+}
+
+func (p Problem) String() string {
+	if p.Warning {
+		return fmt.Sprintf("%s: %s (warning)", p.Frame, p.Reason)
+	}
+	return fmt.Sprintf("%s: %s", p.Frame, p.Reason)
+}
+
+type problem struct {
+	Problem
+	// The index of the offending frame, or -1 if all frames of
+	// that type are affected.
+	index int
+}
+
+func (t *Tag) problems() []problem {
+	var problems []problem
+	for id := range t.Frames {
+		if _, ok := FrameNames[id]; !ok {
+			problems = append(problems, problem{Problem{Frame: id, Reason: "unknown frame"}, -1})
+		}
+	}
 
 	if t.HasFrame("TSRC") && len(t.GetTextFrame("TSRC")) != 12 {
-		// TODO invalid TSRC frame
+		problems = append(problems, problem{Problem{Frame: "TSRC", Reason: "ISRC must be 12 characters long"}, -1})
 	}
 
-	return nil
+	// There may only be one file icon and one other file icon.
+	// Multiple front covers are allowed, but confuse players.
+	seen := make(map[PictureType]bool)
+	for i, frame := range t.Frames["APIC"] {
+		pic, ok := frame.(PictureFrame)
+		if !ok {
+			continue
+		}
+		if seen[pic.PictureType] {
+			reason := fmt.Sprintf("more than one picture of type %q", pic.PictureType)
+			switch pic.PictureType {
+			case 1, 2:
+				problems = append(problems, problem{Problem{Frame: "APIC", Reason: reason}, i})
+			case 3:
+				problems = append(problems, problem{Problem{Frame: "APIC", Reason: reason, Warning: true}, i})
+			}
+		}
+		seen[pic.PictureType] = true
+	}
+
+	// Invalid language codes don't affect the rest of the frame, so
+	// they only cause warnings. The specification uses XXX for
+	// unknown languages.
+	checkLanguage := func(id FrameType, i int, lang string) {
+		if lang != "XXX" && !ValidLanguage(lang) {
+			reason := fmt.Sprintf("invalid language %q", lang)
+			problems = append(problems, problem{Problem{Frame: id, Reason: reason, Warning: true}, i})
+		}
+	}
+	for _, id := range []FrameType{"COMM", "USLT", "SYLT", "USER"} {
+		for i, frame := range t.Frames[id] {
+			if lang, ok := frameLanguage(frame); ok {
+				checkLanguage(id, i, lang)
+			}
+		}
+	}
+	for _, lang := range t.Languages() {
+		checkLanguage("TLAN", -1, lang)
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Frame < problems[j].Frame
+	})
+	return problems
+}
+
+// frameLanguage returns the language of frames that have one.
+func frameLanguage(frame Frame) (string, bool) {
+	switch frame := frame.(type) {
+	case CommentFrame:
+		return frame.Language, true
+	case UnsynchronisedLyricsFrame:
+		return frame.Language, true
+	case SynchronisedLyricsFrame:
+		return frame.Language, true
+	case TermsOfUseFrame:
+		return frame.Language, true
+	default:
+		return "", false
+	}
 }
 
-// Sanitize will remove all frames that aren't valid. Check the
-// documentation of (*Tag).Validate() to see what "valid" means.
-func (t *Tag) Sanitize() {
-	panic("not implemented") // FIXME
+// normalizeLanguages replaces the language codes of all frames with
+// their normalized forms.
+func (t *Tag) normalizeLanguages() {
+	for _, id := range []FrameType{"COMM", "USLT", "SYLT", "USER"} {
+		for i, frame := range t.Frames[id] {
+			switch frame := frame.(type) {
+			case CommentFrame:
+				frame.Language = NormalizeLanguage(frame.Language)
+				t.Frames[id][i] = frame
+			case UnsynchronisedLyricsFrame:
+				frame.Language = NormalizeLanguage(frame.Language)
+				t.Frames[id][i] = frame
+			case SynchronisedLyricsFrame:
+				frame.Language = NormalizeLanguage(frame.Language)
+				t.Frames[id][i] = frame
+			case TermsOfUseFrame:
+				frame.Language = NormalizeLanguage(frame.Language)
+				t.Frames[id][i] = frame
+			}
+		}
+	}
+	if langs := t.Languages(); len(langs) > 0 {
+		for i, lang := range langs {
+			langs[i] = NormalizeLanguage(lang)
+		}
+		t.SetLanguages(langs)
+	}
 }
 
 func (t *Tag) Album() string {
@@ -279,7 +1025,7 @@ func (t *Tag) Artists() []string {
 }
 
 func (t *Tag) SetArtists(artists []string) {
-	t.SetTextFrameSlice("TPE1", artists)
+	t.SetTextFrameSliceNormalized("TPE1", artists)
 }
 
 func (t *Tag) Artist() string {
@@ -345,7 +1091,7 @@ func (t *Tag) Composers() []string {
 }
 
 func (t *Tag) SetComposers(composers []string) {
-	t.SetTextFrameSlice("TCOM", composers)
+	t.SetTextFrameSliceNormalized("TCOM", composers)
 }
 
 func (t *Tag) Composer() string {
@@ -369,12 +1115,23 @@ func (t *Tag) SetTitle(title string) {
 	t.SetTextFrame("TIT2", title)
 }
 
+// Length returns the length stored in the TLEN frame. Use LengthFrom
+// to fall back to the audio data if the frame is absent.
 func (t *Tag) Length() time.Duration {
-	// TODO if TLEN frame doesn't exist determine the length by
-	// parsing the underlying audio file
 	return time.Duration(t.GetTextFrameNumber("TLEN")) * time.Millisecond
 }
 
+// LengthFrom returns the length stored in the TLEN frame. If there is
+// no such frame, it determines the length from the audio data read
+// from audio, as described by AudioLength.
+func (t *Tag) LengthFrom(audio io.Reader) (time.Duration, error) {
+	if t.HasFrame("TLEN") {
+		return t.Length(), nil
+	}
+
+	return AudioLength(audio)
+}
+
 func (t *Tag) SetLength(d time.Duration) {
 	t.SetTextFrameNumber("TLEN", int(d.Nanoseconds()/1e6))
 }
@@ -393,13 +1150,34 @@ func (t *Tag) Language() string {
 }
 
 func (t *Tag) SetLanguages(langs []string) {
-	t.SetTextFrameSlice("TLAN", langs)
+	t.SetTextFrameSliceNormalized("TLAN", langs)
 }
 
 func (t *Tag) SetLanguage(lang string) {
 	t.SetTextFrame("TLAN", lang)
 }
 
+// Genres returns the content types of the track. References to ID3v1
+// genres are replaced with their names.
+func (t *Tag) Genres() []string {
+	genres := t.GetTextFrameSlice("TCON")
+	for i, genre := range genres {
+		genres[i] = genreName(genre)
+	}
+	return genres
+}
+
+// SetGenres sets the content types of the track. They are stored as
+// plain names, separated by null bytes, except for Remix and Cover,
+// which are stored as the keywords RX and CR.
+func (t *Tag) SetGenres(genres []string) {
+	values := make([]string, len(genres))
+	for i, genre := range genres {
+		values[i] = genreKeyword(genre)
+	}
+	t.SetTextFrameSliceNormalized("TCON", values)
+}
+
 func (t *Tag) Publisher() string {
 	return t.GetTextFrame("TPUB")
 }
@@ -440,6 +1218,18 @@ func (t *Tag) SetRecordingTime(rt time.Time) {
 	t.SetTextFrameTime("TDRC", rt)
 }
 
+// RecordingTimePrecision returns the precision of the recording time,
+// which may only consist of a year, for example.
+func (t *Tag) RecordingTimePrecision() Precision {
+	return t.GetTextFrameTimePrecision("TDRC")
+}
+
+// SetRecordingTimeWithPrecision sets the recording time, storing only
+// the components up to precision p.
+func (t *Tag) SetRecordingTimeWithPrecision(rt time.Time, p Precision) {
+	t.SetTextFrameTimePrecision("TDRC", rt, p)
+}
+
 func (t *Tag) OriginalReleaseTime() time.Time {
 	return t.GetTextFrameTime("TDOR")
 }
@@ -448,6 +1238,18 @@ func (t *Tag) SetOriginalReleaseTime(rt time.Time) {
 	t.SetTextFrameTime("TDOR", rt)
 }
 
+// OriginalReleaseTimePrecision returns the precision of the original
+// release time, which may only consist of a year, for example.
+func (t *Tag) OriginalReleaseTimePrecision() Precision {
+	return t.GetTextFrameTimePrecision("TDOR")
+}
+
+// SetOriginalReleaseTimeWithPrecision sets the original release time,
+// storing only the components up to precision p.
+func (t *Tag) SetOriginalReleaseTimeWithPrecision(rt time.Time, p Precision) {
+	t.SetTextFrameTimePrecision("TDOR", rt, p)
+}
+
 func (t *Tag) OriginalFilename() string {
 	return t.GetTextFrame("TOFN")
 }
@@ -472,62 +1274,432 @@ func (t *Tag) SetEncodingTime(et time.Time) {
 	t.SetTextFrameTime("TDEN", et)
 }
 
-func (t *Tag) AlbumSortOrder() string {
-	return t.GetTextFrame("TSOA")
+func (t *Tag) Copyright() string {
+	return t.GetTextFrame("TCOP")
 }
 
-func (t *Tag) SetAlbumSortOrder(s string) {
-	t.SetTextFrame("TSOA", s)
+func (t *Tag) SetCopyright(copyright string) {
+	t.SetTextFrame("TCOP", copyright)
 }
 
-func (t *Tag) PerformerSortOrder() string {
-	return t.GetTextFrame("TSOP")
+func (t *Tag) EncodedBy() string {
+	return t.GetTextFrame("TENC")
 }
 
-func (t *Tag) SetPerformerSortOrder(s string) {
-	t.SetTextFrame("TSOP", s)
+func (t *Tag) SetEncodedBy(name string) {
+	t.SetTextFrame("TENC", name)
 }
 
-func (t *Tag) TitleSortOrder() string {
-	return t.GetTextFrame("TSOT")
+func (t *Tag) MediaType() string {
+	return t.GetTextFrame("TMED")
 }
 
-func (t *Tag) SetTitleSortOrder(s string) {
-	t.SetTextFrame("TSOT", s)
+func (t *Tag) SetMediaType(typ string) {
+	t.SetTextFrame("TMED", typ)
 }
 
-func (t *Tag) ISRC() string {
-	return t.GetTextFrame("TSRC")
+func (t *Tag) FileType() string {
+	return t.GetTextFrame("TFLT")
 }
 
-func (t *Tag) SetISRC(isrc string) {
-	t.SetTextFrame("TSRC", isrc)
+func (t *Tag) SetFileType(typ string) {
+	t.SetTextFrame("TFLT", typ)
 }
 
-func (t *Tag) Mood() string {
-	return t.GetTextFrame("TMOO")
+func (t *Tag) InitialKey() string {
+	return t.GetTextFrame("TKEY")
 }
 
-func (t *Tag) SetMood(mood string) {
-	t.SetTextFrame("TMOO", mood)
+func (t *Tag) SetInitialKey(key string) {
+	t.SetTextFrame("TKEY", key)
 }
 
-func (t *Tag) Comments() []Comment {
-	frames := t.Frames["COMM"]
-	comments := make([]Comment, len(frames))
+func (t *Tag) ContentGroup() string {
+	return t.GetTextFrame("TIT1")
+}
 
-	for i, frame := range frames {
-		comment := frame.(CommentFrame)
-		comments[i] = Comment{
-			Language:    comment.Language,
+func (t *Tag) SetContentGroup(group string) {
+	t.SetTextFrame("TIT1", group)
+}
+
+func (t *Tag) Subtitle() string {
+	return t.GetTextFrame("TIT3")
+}
+
+func (t *Tag) SetSubtitle(subtitle string) {
+	t.SetTextFrame("TIT3", subtitle)
+}
+
+// DiscSubtitle returns the subtitle of the part of the set (TSST)
+// that this track belongs to.
+func (t *Tag) DiscSubtitle() string {
+	return t.GetTextFrame("TSST")
+}
+
+func (t *Tag) SetDiscSubtitle(subtitle string) {
+	t.SetTextFrame("TSST", subtitle)
+}
+
+func (t *Tag) ProducedNotice() string {
+	return t.GetTextFrame("TPRO")
+}
+
+func (t *Tag) SetProducedNotice(notice string) {
+	t.SetTextFrame("TPRO", notice)
+}
+
+func (t *Tag) EncodingSoftware() string {
+	return t.GetTextFrame("TSSE")
+}
+
+func (t *Tag) SetEncodingSoftware(software string) {
+	t.SetTextFrame("TSSE", software)
+}
+
+func (t *Tag) Remixer() string {
+	return t.GetTextFrame("TPE4")
+}
+
+func (t *Tag) SetRemixer(name string) {
+	t.SetTextFrame("TPE4", name)
+}
+
+func (t *Tag) OriginalAlbum() string {
+	return t.GetTextFrame("TOAL")
+}
+
+func (t *Tag) SetOriginalAlbum(album string) {
+	t.SetTextFrame("TOAL", album)
+}
+
+func (t *Tag) ReleaseTime() time.Time {
+	return t.GetTextFrameTime("TDRL")
+}
+
+func (t *Tag) SetReleaseTime(rt time.Time) {
+	t.SetTextFrameTime("TDRL", rt)
+}
+
+// ReleaseTimePrecision returns the precision of the release time.
+func (t *Tag) ReleaseTimePrecision() Precision {
+	return t.GetTextFrameTimePrecision("TDRL")
+}
+
+// SetReleaseTimeWithPrecision sets the release time, storing only the
+// components up to precision p.
+func (t *Tag) SetReleaseTimeWithPrecision(rt time.Time, p Precision) {
+	t.SetTextFrameTimePrecision("TDRL", rt, p)
+}
+
+func (t *Tag) TaggingTime() time.Time {
+	return t.GetTextFrameTime("TDTG")
+}
+
+func (t *Tag) SetTaggingTime(rt time.Time) {
+	t.SetTextFrameTime("TDTG", rt)
+}
+
+func (t *Tag) Lyricists() []string {
+	return t.GetTextFrameSlice("TEXT")
+}
+
+func (t *Tag) SetLyricists(names []string) {
+	t.SetTextFrameSlice("TEXT", names)
+}
+
+func (t *Tag) Lyricist() string {
+	names := t.Lyricists()
+	if len(names) > 0 {
+		return names[0]
+	}
+
+	return ""
+}
+
+func (t *Tag) SetLyricist(name string) {
+	t.SetTextFrame("TEXT", name)
+}
+
+func (t *Tag) OriginalLyricists() []string {
+	return t.GetTextFrameSlice("TOLY")
+}
+
+func (t *Tag) SetOriginalLyricists(names []string) {
+	t.SetTextFrameSlice("TOLY", names)
+}
+
+func (t *Tag) OriginalLyricist() string {
+	names := t.OriginalLyricists()
+	if len(names) > 0 {
+		return names[0]
+	}
+
+	return ""
+}
+
+func (t *Tag) SetOriginalLyricist(name string) {
+	t.SetTextFrame("TOLY", name)
+}
+
+func (t *Tag) AlbumSortOrder() string {
+	return t.GetTextFrame("TSOA")
+}
+
+func (t *Tag) SetAlbumSortOrder(s string) {
+	t.SetTextFrame("TSOA", s)
+}
+
+func (t *Tag) PerformerSortOrder() string {
+	return t.GetTextFrame("TSOP")
+}
+
+func (t *Tag) SetPerformerSortOrder(s string) {
+	t.SetTextFrame("TSOP", s)
+}
+
+func (t *Tag) TitleSortOrder() string {
+	return t.GetTextFrame("TSOT")
+}
+
+func (t *Tag) SetTitleSortOrder(s string) {
+	t.SetTextFrame("TSOT", s)
+}
+
+func (t *Tag) AlbumArtistSortOrder() string {
+	return t.GetTextFrame("TSO2")
+}
+
+func (t *Tag) SetAlbumArtistSortOrder(s string) {
+	t.SetTextFrame("TSO2", s)
+}
+
+func (t *Tag) ComposerSortOrder() string {
+	return t.GetTextFrame("TSOC")
+}
+
+func (t *Tag) SetComposerSortOrder(s string) {
+	t.SetTextFrame("TSOC", s)
+}
+
+// Compilation reports whether the file is part of a compilation, as
+// indicated by the iTunes TCMP frame.
+func (t *Tag) Compilation() bool {
+	return t.GetTextFrame("TCMP") == "1"
+}
+
+// SetCompilation sets the iTunes TCMP frame. Like iTunes, it writes
+// "0" instead of removing the frame when b is false.
+func (t *Tag) SetCompilation(b bool) {
+	if b {
+		t.SetTextFrame("TCMP", "1")
+	} else {
+		t.SetTextFrame("TCMP", "0")
+	}
+}
+
+// InvolvedPeople returns the involved people list (TIPL). An
+// unpaired trailing entry is ignored.
+func (t *Tag) InvolvedPeople() []InvolvedPerson {
+	return t.getInvolvedPeople("TIPL")
+}
+
+func (t *Tag) SetInvolvedPeople(people []InvolvedPerson) {
+	t.setInvolvedPeople("TIPL", people)
+}
+
+// MusicianCredits returns the musician credits list (TMCL). An
+// unpaired trailing entry is ignored.
+func (t *Tag) MusicianCredits() []InvolvedPerson {
+	return t.getInvolvedPeople("TMCL")
+}
+
+func (t *Tag) SetMusicianCredits(people []InvolvedPerson) {
+	t.setInvolvedPeople("TMCL", people)
+}
+
+func (t *Tag) getInvolvedPeople(name FrameType) []InvolvedPerson {
+	values := t.GetTextFrameSlice(name)
+	if len(values) < 2 {
+		return nil
+	}
+
+	people := make([]InvolvedPerson, len(values)/2)
+	for i := range people {
+		people[i] = InvolvedPerson{Role: values[2*i], Name: values[2*i+1]}
+	}
+
+	return people
+}
+
+func (t *Tag) setInvolvedPeople(name FrameType, people []InvolvedPerson) {
+	values := make([]string, 0, len(people)*2)
+	for _, person := range people {
+		values = append(values, person.Role, person.Name)
+	}
+	t.SetTextFrameSlice(name, values)
+}
+
+func (t *Tag) CommercialURLs() []string {
+	return t.getURLFrameSlice("WCOM")
+}
+
+func (t *Tag) SetCommercialURLs(urls []string) {
+	t.setURLFrameSlice("WCOM", urls)
+}
+
+func (t *Tag) CommercialURL() string {
+	return t.getURLFrame("WCOM")
+}
+
+func (t *Tag) SetCommercialURL(url string) {
+	t.setURLFrameSlice("WCOM", []string{url})
+}
+
+func (t *Tag) CopyrightURL() string {
+	return t.getURLFrame("WCOP")
+}
+
+func (t *Tag) SetCopyrightURL(url string) {
+	t.setURLFrameSlice("WCOP", []string{url})
+}
+
+func (t *Tag) AudioFileURL() string {
+	return t.getURLFrame("WOAF")
+}
+
+func (t *Tag) SetAudioFileURL(url string) {
+	t.setURLFrameSlice("WOAF", []string{url})
+}
+
+func (t *Tag) ArtistURLs() []string {
+	return t.getURLFrameSlice("WOAR")
+}
+
+func (t *Tag) SetArtistURLs(urls []string) {
+	t.setURLFrameSlice("WOAR", urls)
+}
+
+func (t *Tag) ArtistURL() string {
+	return t.getURLFrame("WOAR")
+}
+
+func (t *Tag) SetArtistURL(url string) {
+	t.setURLFrameSlice("WOAR", []string{url})
+}
+
+func (t *Tag) AudioSourceURL() string {
+	return t.getURLFrame("WOAS")
+}
+
+func (t *Tag) SetAudioSourceURL(url string) {
+	t.setURLFrameSlice("WOAS", []string{url})
+}
+
+func (t *Tag) RadioStationURL() string {
+	return t.getURLFrame("WORS")
+}
+
+func (t *Tag) SetRadioStationURL(url string) {
+	t.setURLFrameSlice("WORS", []string{url})
+}
+
+func (t *Tag) PaymentURL() string {
+	return t.getURLFrame("WPAY")
+}
+
+func (t *Tag) SetPaymentURL(url string) {
+	t.setURLFrameSlice("WPAY", []string{url})
+}
+
+func (t *Tag) PublisherURL() string {
+	return t.getURLFrame("WPUB")
+}
+
+func (t *Tag) SetPublisherURL(url string) {
+	t.setURLFrameSlice("WPUB", []string{url})
+}
+
+func (t *Tag) ISRC() string {
+	return t.GetTextFrame("TSRC")
+}
+
+func (t *Tag) SetISRC(isrc string) {
+	t.SetTextFrame("TSRC", isrc)
+}
+
+func (t *Tag) Mood() string {
+	return t.GetTextFrame("TMOO")
+}
+
+func (t *Tag) SetMood(mood string) {
+	t.SetTextFrame("TMOO", mood)
+}
+
+const musicBrainzOwner = "http://musicbrainz.org"
+
+// MusicBrainzTrackID returns the MusicBrainz recording ID, which is
+// stored in the UFID frame owned by MusicBrainz.
+func (t *Tag) MusicBrainzTrackID() string {
+	id, _ := t.UniqueFileID(musicBrainzOwner)
+	return string(id)
+}
+
+func (t *Tag) SetMusicBrainzTrackID(id string) {
+	t.SetUniqueFileID(musicBrainzOwner, []byte(id))
+}
+
+func (t *Tag) MusicBrainzAlbumID() string {
+	return t.GetTextFrame("TXXX:MusicBrainz Album Id")
+}
+
+func (t *Tag) SetMusicBrainzAlbumID(id string) {
+	t.SetTextFrame("TXXX:MusicBrainz Album Id", id)
+}
+
+func (t *Tag) MusicBrainzArtistID() string {
+	return t.GetTextFrame("TXXX:MusicBrainz Artist Id")
+}
+
+func (t *Tag) SetMusicBrainzArtistID(id string) {
+	t.SetTextFrame("TXXX:MusicBrainz Artist Id", id)
+}
+
+func (t *Tag) MusicBrainzAlbumArtistID() string {
+	return t.GetTextFrame("TXXX:MusicBrainz Album Artist Id")
+}
+
+func (t *Tag) SetMusicBrainzAlbumArtistID(id string) {
+	t.SetTextFrame("TXXX:MusicBrainz Album Artist Id", id)
+}
+
+func (t *Tag) MusicBrainzReleaseGroupID() string {
+	return t.GetTextFrame("TXXX:MusicBrainz Release Group Id")
+}
+
+func (t *Tag) SetMusicBrainzReleaseGroupID(id string) {
+	t.SetTextFrame("TXXX:MusicBrainz Release Group Id", id)
+}
+
+func (t *Tag) Comments() []Comment {
+	var comments []Comment
+	for _, frame := range t.Frames["COMM"] {
+		comment, ok := frame.(CommentFrame)
+		if !ok {
+			continue
+		}
+		comments = append(comments, Comment{
+			Language:    comment.Language,
 			Description: comment.Description,
 			Text:        comment.Text,
-		}
+		})
 	}
 
 	return comments
 }
 
+// SetComments replaces all comments with the provided ones. Comments
+// that iTunes uses to store machine-readable data, like iTunNORM, are
+// kept unless comments contains a replacement.
 func (t *Tag) SetComments(comments []Comment) {
 	frames := make([]Frame, len(comments))
 	for i, comment := range comments {
@@ -540,12 +1712,230 @@ func (t *Tag) SetComments(comments []Comment) {
 			Text:        comment.Text,
 		}
 	}
+
+outer:
+	for _, frame := range t.Frames["COMM"] {
+		old, ok := frame.(CommentFrame)
+		if !ok || !strings.HasPrefix(old.Description, "iTun") {
+			continue
+		}
+		for _, comment := range comments {
+			if comment.Language == old.Language && comment.Description == old.Description {
+				continue outer
+			}
+		}
+		frames = append(frames, old)
+	}
+
 	t.Frames["COMM"] = frames
 }
 
+// ITunesNormalization returns the volume normalization data that
+// iTunes stores in a comment with the description iTunNORM.
+func (t *Tag) ITunesNormalization() (string, bool) {
+	for _, comment := range t.Comments() {
+		if comment.Description == "iTunNORM" {
+			return comment.Text, true
+		}
+	}
+
+	return "", false
+}
+
+// SetITunesNormalization sets the volume normalization data that
+// iTunes stores in a comment with the description iTunNORM.
+func (t *Tag) SetITunesNormalization(norm string) {
+	lang := "eng"
+	for _, comment := range t.Comments() {
+		if comment.Description == "iTunNORM" {
+			lang = comment.Language
+			break
+		}
+	}
+	t.SetComment(Comment{Language: lang, Description: "iTunNORM", Text: norm})
+}
+
+// TermsOfUse returns the terms of use (USER) in the given language.
+func (t *Tag) TermsOfUse(lang string) string {
+	for _, frame := range t.Frames["USER"] {
+		user, ok := frame.(TermsOfUseFrame)
+		if ok && user.Language == lang {
+			return user.Text
+		}
+	}
+
+	return ""
+}
+
+// SetTermsOfUse sets the terms of use (USER) in the given language.
+// Terms of use in other languages are left untouched.
+func (t *Tag) SetTermsOfUse(lang, text string) {
+	frame := TermsOfUseFrame{
+		FrameHeader: FrameHeader{id: "USER"},
+		Language:    lang,
+		Text:        text,
+	}
+
+	frames := t.Frames["USER"]
+	for i := range frames {
+		if old, ok := frames[i].(TermsOfUseFrame); ok && old.Language == lang {
+			frame.flags = frames[i].Header().flags & statusFlags
+			frames[i] = frame
+			return
+		}
+	}
+
+	t.Frames["USER"] = append(frames, frame)
+}
+
+// Comment returns the comment with the given language and
+// description. The main comment, which most players display, has
+// an empty description. Languages are compared after normalizing
+// them with NormalizeLanguage.
+func (t *Tag) Comment(lang, description string) (Comment, bool) {
+	lang = NormalizeLanguage(lang)
+	for _, comment := range t.Comments() {
+		if NormalizeLanguage(comment.Language) == lang && comment.Description == description {
+			return comment, true
+		}
+	}
+
+	return Comment{}, false
+}
+
+// SetComment sets a comment, replacing an existing comment with the
+// same language and description. Other comments are left untouched.
+// The language is normalized with NormalizeLanguage.
+func (t *Tag) SetComment(comment Comment) {
+	comment.Language = NormalizeLanguage(comment.Language)
+	frame := CommentFrame{
+		FrameHeader: FrameHeader{
+			id: "COMM",
+		},
+		Language:    comment.Language,
+		Description: comment.Description,
+		Text:        comment.Text,
+	}
+
+	frames := t.Frames["COMM"]
+	for i := range frames {
+		old, ok := frames[i].(CommentFrame)
+		if ok && NormalizeLanguage(old.Language) == comment.Language && old.Description == comment.Description {
+			frame.flags = old.flags & statusFlags
+			frames[i] = frame
+			return
+		}
+	}
+
+	t.Frames["COMM"] = append(frames, frame)
+}
+
+// EventTimings returns the event timing codes (ETCO) and the format
+// of their timestamps.
+func (t *Tag) EventTimings() (TimestampFormat, []TimingEvent) {
+	for _, frame := range t.Frames["ETCO"] {
+		if frame, ok := frame.(EventTimingFrame); ok {
+			return frame.TimestampFormat, frame.Events
+		}
+	}
+
+	return 0, nil
+}
+
+func (t *Tag) SetEventTimings(format TimestampFormat, events []TimingEvent) {
+	t.Frames["ETCO"] = []Frame{EventTimingFrame{
+		FrameHeader:     FrameHeader{id: "ETCO"},
+		TimestampFormat: format,
+		Events:          events,
+	}}
+}
+
+// PositionSync returns the position synchronisation (POSS), which
+// is the position in the audio at which the file starts.
+func (t *Tag) PositionSync() (TimestampFormat, uint32, bool) {
+	for _, frame := range t.Frames["POSS"] {
+		if frame, ok := frame.(PositionSyncFrame); ok {
+			return frame.TimestampFormat, frame.Position, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+func (t *Tag) SetPositionSync(format TimestampFormat, position uint32) {
+	t.Frames["POSS"] = []Frame{PositionSyncFrame{
+		FrameHeader:     FrameHeader{id: "POSS"},
+		TimestampFormat: format,
+		Position:        position,
+	}}
+}
+
+// AudioEncryption returns all audio encryption frames (AENC).
+func (t *Tag) AudioEncryption() []AudioEncryptionFrame {
+	var res []AudioEncryptionFrame
+	for _, frame := range t.Frames["AENC"] {
+		if frame, ok := frame.(AudioEncryptionFrame); ok {
+			res = append(res, frame)
+		}
+	}
+
+	return res
+}
+
+// LinkedInformation returns all linked information frames (LINK).
+func (t *Tag) LinkedInformation() []LinkFrame {
+	var res []LinkFrame
+	for _, frame := range t.Frames["LINK"] {
+		if frame, ok := frame.(LinkFrame); ok {
+			res = append(res, frame)
+		}
+	}
+
+	return res
+}
+
+// Signatures returns all signature frames (SIGN).
+func (t *Tag) Signatures() []SignatureFrame {
+	var res []SignatureFrame
+	for _, frame := range t.Frames["SIGN"] {
+		if frame, ok := frame.(SignatureFrame); ok {
+			res = append(res, frame)
+		}
+	}
+
+	return res
+}
+
+// SynchronisedLyrics returns all synchronised lyrics (SYLT).
+func (t *Tag) SynchronisedLyrics() []SynchronisedLyricsFrame {
+	var res []SynchronisedLyricsFrame
+	for _, frame := range t.Frames["SYLT"] {
+		if frame, ok := frame.(SynchronisedLyricsFrame); ok {
+			res = append(res, frame)
+		}
+	}
+
+	return res
+}
+
+// Commercials returns all commercial frames (COMR).
+func (t *Tag) Commercials() []CommercialFrame {
+	var res []CommercialFrame
+	for _, frame := range t.Frames["COMR"] {
+		if frame, ok := frame.(CommercialFrame); ok {
+			res = append(res, frame)
+		}
+	}
+
+	return res
+}
+
 func (t *Tag) HasFrame(name FrameType) bool {
-	_, ok := t.Frames[name]
-	return ok
+	if userFrameName, ok := frameNameToUserFrame(name); ok {
+		_, ok := t.getUserTextFrame(userFrameName)
+		return ok
+	}
+	return len(t.Frames[name]) > 0
 }
 
 // GetTextFrame returns the text frame specified by name.
@@ -553,36 +1943,152 @@ func (t *Tag) HasFrame(name FrameType) bool {
 // To access user text frames, specify the name like "TXXX:The
 // description".
 func (t *Tag) GetTextFrame(name FrameType) string {
+	text, _ := t.LookupTextFrame(name)
+	return text
+}
+
+// LookupTextFrame is like GetTextFrame, but also reports whether the
+// frame exists. This allows distinguishing between absent frames and
+// frames containing an empty string.
+func (t *Tag) LookupTextFrame(name FrameType) (string, bool) {
 	userFrameName, ok := frameNameToUserFrame(name)
 	if ok {
 		return t.getUserTextFrame(userFrameName)
 	}
 
 	// Get normal text frame
-	frames := t.Frames[name]
-	if len(frames) == 0 {
-		return ""
+	for _, frame := range t.Frames[name] {
+		if !undecodable(frame) {
+			return frame.Value(), true
+		}
 	}
 
-	return frames[0].Value()
+	return "", false
 }
 
-func (t *Tag) getUserTextFrame(name string) string {
-	frames, ok := t.Frames["TXXX"]
-	if !ok {
-		return ""
+func (t *Tag) getUserTextFrame(name string) (string, bool) {
+	for _, frame := range t.Frames["TXXX"] {
+		userFrame, ok := frame.(UserTextInformationFrame)
+		if ok && userFrame.Description == name {
+			return userFrame.Text, true
+		}
+	}
+
+	return "", false
+}
+
+// UniqueFileID returns the identifier of the unique file identifier
+// frame (UFID) owned by owner, which usually is a URL or an email
+// address.
+func (t *Tag) UniqueFileID(owner string) ([]byte, bool) {
+	for _, frame := range t.Frames["UFID"] {
+		ufid, ok := frame.(UniqueFileIdentifierFrame)
+		if ok && ufid.Owner == owner {
+			return ufid.Identifier, true
+		}
+	}
+
+	return nil, false
+}
+
+// SetUniqueFileID sets the identifier of the unique file identifier
+// frame (UFID) owned by owner. Frames of other owners are left
+// untouched. The specification limits identifiers to 64 bytes of
+// binary data.
+func (t *Tag) SetUniqueFileID(owner string, id []byte) {
+	frame := UniqueFileIdentifierFrame{
+		FrameHeader: FrameHeader{id: "UFID"},
+		Owner:       owner,
+		Identifier:  id,
 	}
 
-	for _, frame := range frames {
-		userFrame := frame.(UserTextInformationFrame)
-		if userFrame.Description == name {
-			return userFrame.Text
+	frames := t.Frames["UFID"]
+	for i := range frames {
+		if old, ok := frames[i].(UniqueFileIdentifierFrame); ok && old.Owner == owner {
+			frame.flags = frames[i].Header().flags & statusFlags
+			frames[i] = frame
+			return
+		}
+	}
+
+	t.Frames["UFID"] = append(frames, frame)
+}
+
+func (t *Tag) getURLFrame(name FrameType) string {
+	for _, frame := range t.Frames[name] {
+		if !undecodable(frame) {
+			return frame.Value()
+		}
+	}
+
+	return ""
+}
+
+// getURLFrameSlice returns the URLs of all URL link frames with the
+// given name. Unlike text frames, URL link frames that allow multiple
+// values do so by repeating the frame.
+func (t *Tag) getURLFrameSlice(name FrameType) []string {
+	var urls []string
+	for _, frame := range t.Frames[name] {
+		if !undecodable(frame) {
+			urls = append(urls, frame.Value())
+		}
+	}
+
+	return urls
+}
+
+func (t *Tag) setURLFrameSlice(name FrameType, urls []string) {
+	if len(urls) == 0 {
+		t.RemoveFrames(name)
+		return
+	}
+
+	frames := make([]Frame, len(urls))
+	for i, url := range urls {
+		frames[i] = URLLinkFrame{
+			FrameHeader: FrameHeader{id: name},
+			URL:         url,
+		}
+	}
+	t.Frames[name] = frames
+}
+
+// UserURL returns the URL of the user defined URL link frame (WXXX)
+// with the given description.
+func (t *Tag) UserURL(description string) string {
+	for _, frame := range t.Frames["WXXX"] {
+		userFrame, ok := frame.(UserDefinedURLLinkFrame)
+		if ok && userFrame.Description == description {
+			return userFrame.URL
 		}
 	}
 
 	return ""
 }
 
+// SetUserURL sets the URL of the user defined URL link frame (WXXX)
+// with the given description. Frames with different descriptions are
+// left untouched.
+func (t *Tag) SetUserURL(description, url string) {
+	frame := UserDefinedURLLinkFrame{
+		FrameHeader: FrameHeader{id: "WXXX"},
+		Description: description,
+		URL:         url,
+	}
+
+	frames := t.Frames["WXXX"]
+	for i := range frames {
+		if old, ok := frames[i].(UserDefinedURLLinkFrame); ok && old.Description == description {
+			frame.flags = frames[i].Header().flags & statusFlags
+			frames[i] = frame
+			return
+		}
+	}
+
+	t.Frames["WXXX"] = append(frames, frame)
+}
+
 func (t *Tag) GetTextFrameNumber(name FrameType) int {
 	s := t.GetTextFrame(name)
 	if s == "" {
@@ -602,6 +2108,24 @@ func (t *Tag) GetTextFrameSlice(name FrameType) []string {
 	return strings.Split(s, "\x00")
 }
 
+// GetTextFrameTimePrecision returns the precision of the timestamp
+// stored in the frame. It returns zero if the frame doesn't exist or
+// doesn't contain a valid timestamp.
+func (t *Tag) GetTextFrameTimePrecision(name FrameType) Precision {
+	s := t.GetTextFrame(name)
+	for i, format := range timeFormats {
+		if _, err := time.Parse(format, s); err == nil {
+			return PrecisionSecond - Precision(i)
+		}
+	}
+	return 0
+}
+
+// GetTextFrameTime returns the timestamp stored in the frame. Missing
+// components, like the month of a timestamp that only has a year, are
+// set to their lowest values. It returns the zero time if the frame
+// doesn't exist or doesn't contain a valid timestamp; use
+// GetTextFrameTimePrecision to tell these cases apart.
 func (t *Tag) GetTextFrameTime(name FrameType) time.Time {
 	s := t.GetTextFrame(name)
 	if s == "" {
@@ -610,8 +2134,7 @@ func (t *Tag) GetTextFrameTime(name FrameType) time.Time {
 
 	ft, err := parseTime(s)
 	if err != nil {
-		// FIXME figure out a way to signal format errors
-		panic(err)
+		return time.Time{}
 	}
 
 	return ft
@@ -624,18 +2147,41 @@ func (t *Tag) SetTextFrame(name FrameType, value string) {
 		return
 	}
 
+	header := FrameHeader{id: name}
 	frames, ok := t.Frames[name]
-	if !ok {
+	if ok {
+		header.flags = frames[0].Header().flags & statusFlags
+	} else {
 		frames = make([]Frame, 1)
 		t.Frames[name] = frames
 	}
 	frames[0] = TextInformationFrame{
-		FrameHeader: FrameHeader{
-			id: name,
-		},
-		Text: value,
+		FrameHeader: header,
+		Text:        value,
+		Encoding:    utf8,
+	}
+}
+
+// SetTextFrameChecked is like SetTextFrame, but returns an
+// InvalidFrameTypeError instead of setting the frame if name is
+// neither a known text frame nor of the form "TXXX:description".
+func (t *Tag) SetTextFrameChecked(name FrameType, value string) error {
+	if !validTextFrameType(name) {
+		return InvalidFrameTypeError{name}
 	}
-	// TODO what about flags and preserving them?
+	t.SetTextFrame(name, value)
+	return nil
+}
+
+func validTextFrameType(name FrameType) bool {
+	if len(name) > 5 && name[:5] == "TXXX:" {
+		return true
+	}
+	if len(name) != 4 || name[0] != 'T' || name == "TXXX" {
+		return false
+	}
+	_, ok := FrameNames[name]
+	return ok
 }
 
 func (t *Tag) setUserTextFrame(name string, value string) {
@@ -646,26 +2192,39 @@ func (t *Tag) setUserTextFrame(name string, value string) {
 		Text:        value,
 	}
 
-	frames, ok := t.Frames["TXXX"]
+	frames := t.Frames["TXXX"]
+	for i := range frames {
+		if old, ok := frames[i].(UserTextInformationFrame); ok && old.Description == name {
+			frame.flags = frames[i].Header().flags & statusFlags
+			frames[i] = frame
+			return
+		}
+	}
+
+	t.Frames["TXXX"] = append(frames, frame)
+}
+
+// removeTextFrame removes the text frame specified by name, which may
+// be of the form "TXXX:description".
+func (t *Tag) removeTextFrame(name FrameType) {
+	userFrameName, ok := frameNameToUserFrame(name)
 	if !ok {
-		frames = make([]Frame, 0)
-		t.Frames["TXXX"] = frames
+		t.RemoveFrames(name)
+		return
 	}
 
-	var i int
-	for i = range frames {
-		if frames[i].(UserTextInformationFrame).Description == name {
-			ok = true
-			break
+	var keep []Frame
+	for _, frame := range t.Frames["TXXX"] {
+		if tf, ok := frame.(UserTextInformationFrame); ok && tf.Description == userFrameName {
+			continue
 		}
+		keep = append(keep, frame)
 	}
-
-	if ok {
-		frames[i] = frame
+	if len(keep) == 0 {
+		delete(t.Frames, "TXXX")
 	} else {
-		t.Frames["TXXX"] = append(t.Frames["TXXX"], frame)
+		t.Frames["TXXX"] = keep
 	}
-
 }
 
 func (t *Tag) SetTextFrameNumber(name FrameType, value int) {
@@ -676,24 +2235,116 @@ func (t *Tag) SetTextFrameSlice(name FrameType, value []string) {
 	t.SetTextFrame(name, strings.Join(value, "\x00"))
 }
 
+// SetTextFrameSliceNormalized is like SetTextFrameSlice, but trims
+// whitespace from the values and drops empty and duplicate values,
+// preserving the order of the remaining ones. If no values remain,
+// the frame is removed.
+func (t *Tag) SetTextFrameSliceNormalized(name FrameType, value []string) {
+	var values []string
+	seen := make(map[string]bool, len(value))
+	for _, v := range value {
+		v = strings.TrimSpace(v)
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		values = append(values, v)
+	}
+
+	if len(values) == 0 {
+		t.removeTextFrame(name)
+		return
+	}
+	t.SetTextFrameSlice(name, values)
+}
+
+// AddTextFrameValue appends value to the list of values of the text
+// frame specified by name, creating the frame if necessary.
+func (t *Tag) AddTextFrameValue(name FrameType, value string) {
+	t.SetTextFrameSlice(name, append(t.GetTextFrameSlice(name), value))
+}
+
+// RemoveTextFrameValue removes the first occurrence of value from the
+// list of values of the text frame specified by name. If no values
+// remain, the frame is removed. It does nothing if the frame or the
+// value don't exist.
+func (t *Tag) RemoveTextFrameValue(name FrameType, value string) {
+	values := t.GetTextFrameSlice(name)
+	for i, v := range values {
+		if v != value {
+			continue
+		}
+
+		values = append(values[:i], values[i+1:]...)
+		if len(values) == 0 {
+			t.removeTextFrame(name)
+		} else {
+			t.SetTextFrameSlice(name, values)
+		}
+		return
+	}
+}
+
 func (t *Tag) SetTextFrameTime(name FrameType, value time.Time) {
 	t.SetTextFrame(name, value.Format(TimeFormat))
 }
 
+// SetTextFrameTimePrecision is like SetTextFrameTime, but only stores
+// the components of value up to precision p.
+func (t *Tag) SetTextFrameTimePrecision(name FrameType, value time.Time, p Precision) {
+	t.SetTextFrame(name, value.Format(p.format()))
+}
+
 // TODO all the other methods
 // TODO UFID
 // TODO USLT
 
 // UserTextFrames returns all TXXX frames.
 func (t *Tag) UserTextFrames() []UserTextInformationFrame {
-	res := make([]UserTextInformationFrame, len(t.Frames["TXXX"]))
-	for i, frame := range t.Frames["TXXX"] {
-		res[i] = frame.(UserTextInformationFrame)
+	var res []UserTextInformationFrame
+	for _, frame := range t.Frames["TXXX"] {
+		if frame, ok := frame.(UserTextInformationFrame); ok {
+			res = append(res, frame)
+		}
 	}
 
 	return res
 }
 
+// UserTextFramesWithPrefix returns all TXXX frames whose descriptions
+// start with prefix.
+func (t *Tag) UserTextFramesWithPrefix(prefix string) []UserTextInformationFrame {
+	var res []UserTextInformationFrame
+	for _, frame := range t.UserTextFrames() {
+		if strings.HasPrefix(frame.Description, prefix) {
+			res = append(res, frame)
+		}
+	}
+
+	return res
+}
+
+// RemoveUserTextFrames removes all TXXX frames whose descriptions
+// start with prefix and returns the number of removed frames.
+func (t *Tag) RemoveUserTextFrames(prefix string) int {
+	var keep []Frame
+	n := 0
+	for _, frame := range t.Frames["TXXX"] {
+		if tf, ok := frame.(UserTextInformationFrame); ok && strings.HasPrefix(tf.Description, prefix) {
+			n++
+			continue
+		}
+		keep = append(keep, frame)
+	}
+	if len(keep) == 0 {
+		delete(t.Frames, "TXXX")
+	} else {
+		t.Frames["TXXX"] = keep
+	}
+
+	return n
+}
+
 func (fm FramesMap) Size() int {
 	size := 0
 	for _, frames := range fm {
@@ -709,11 +2360,13 @@ func desynchsafeInt(b [4]byte) int {
 	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
 }
 
+// synchsafeInt encodes i, which must not be negative or larger than
+// maxTagSize, as a synchsafe integer. Higher bits are discarded.
 func synchsafeInt(i int) int {
 	return (i & 0x7f) |
 		((i & 0x3f80) << 1) |
 		((i & 0x1fc000) << 2) |
-		((i & 0xfe0000) << 3)
+		((i & 0xfe00000) << 3)
 }
 
 func intToBytes(i int) []byte {
@@ -725,33 +2378,25 @@ func intToBytes(i int) []byte {
 	}
 }
 
-func splitNullN(data []byte, encoding Encoding, n int) [][]byte {
-	if encoding == utf8 || encoding == iso88591 {
-		return bytes.SplitN(data, nul, n)
+// nextTerminated splits data after the first string terminator of
+// the given encoding. field doesn't include the terminator. If there
+// is no terminator, all of data is returned as field and ok is false.
+func nextTerminated(data []byte, encoding Encoding) (field []byte, rest []byte, ok bool) {
+	if encoding != utf16bom && encoding != utf16be {
+		i := bytes.IndexByte(data, 0)
+		if i == -1 {
+			return data, nil, false
+		}
+		return data[:i], data[i+1:], true
 	}
 
-	var (
-		matches [][]byte
-		prev    int
-	)
-
-	for i := 0; i < len(data); i += 2 {
-		// TODO if there's no data[i+1] then this is malformed data
-		// and we should return an error
+	for i := 0; i+1 < len(data); i += 2 {
 		if data[i] == 0 && data[i+1] == 0 {
-			matches = append(matches, data[prev:i])
-
-			if len(matches) == n-1 {
-				break
-			}
+			return data[:i], data[i+2:], true
 		}
 	}
 
-	if prev < len(data)-1 {
-		matches = append(matches, data[prev:])
-	}
-
-	return matches
+	return data, nil, false
 }
 
 func parseTime(input string) (res time.Time, err error) {
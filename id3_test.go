@@ -3,6 +3,15 @@ package id3
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -43,6 +52,17 @@ func TestISO88591ToUTF8(t *testing.T) {
 	}
 }
 
+func TestISO88591ToUTF8ASCII(t *testing.T) {
+	in := []byte("plain ASCII")
+	if res := iso88591ToUTF8(in); &res[0] != &in[0] {
+		t.Error("expected ASCII input to be returned unchanged")
+	}
+
+	if res := iso88591ToUTF8([]byte("\x80")); string(res) != "\u0080" {
+		t.Errorf("expected U+0080, got %q", res)
+	}
+}
+
 func TestUTF16ToUTF8(t *testing.T) {
 	in := []byte{254, 255, 0, 74, 0,
 		117, 0, 115, 0, 116, 0, 32, 0, 97, 0, 32, 0, 116, 0, 101, 0, 115,
@@ -151,21 +171,3551 @@ func TestCheck(t *testing.T) {
 	}
 }
 
-func BenchmarkISO88591ToUTF8(b *testing.B) {
-	b.SetBytes(int64(len(ISOTestString)))
-	for i := 0; i < b.N; i++ {
-		_ = iso88591ToUTF8(ISOTestString)
+func TestCheckReader(t *testing.T) {
+	tests := []struct {
+		in string
+		ok bool
+	}{
+		{"ID3stuff", true},
+		{"NotID3stuff", false},
+		{"ID", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		ok, r, err := CheckReader(bytes.NewBufferString(test.in))
+		if err != nil {
+			t.Errorf("Unexpected error %q", err)
+		}
+		if ok != test.ok {
+			t.Errorf("expected ok = %t for %q, got %t", test.ok, test.in, ok)
+		}
+
+		all, _ := ioutil.ReadAll(r)
+		if string(all) != test.in {
+			t.Errorf("expected reader to yield %q, got %q", test.in, all)
+		}
 	}
 }
 
-func BenchmarkUTF8ToISO88591(b *testing.B) {
-	b.SetBytes(int64(len(UTF8TestString)))
-	for i := 0; i < b.N; i++ {
-		_ = utf8ToISO88591(UTF8TestString)
+// parseFrame parses a single frame from b, which must contain
+// exactly one frame including its header.
+func parseFrame(t *testing.T, b []byte) Frame {
+	d := NewDecoder(bytes.NewReader(b))
+	d.r = io.LimitReader(d.r, int64(len(b)))
+	d.h.Version = 0x0400
+	frame, err := d.ParseFrame()
+	if err != nil {
+		t.Fatalf("Couldn't parse frame: %s", err)
+	}
+
+	return frame
+}
+
+// roundTripFrame writes f with an Encoder and parses it back.
+func roundTripFrame(t *testing.T, f Frame) Frame {
+	buf := &bytes.Buffer{}
+	if err := NewEncoder(buf).WriteFrame(f); err != nil {
+		t.Fatalf("Couldn't write frame: %s", err)
+	}
+	if buf.Len() != f.Size() {
+		t.Fatalf("Frame has size %d, but %d bytes were written", f.Size(), buf.Len())
+	}
+
+	return parseFrame(t, buf.Bytes())
+}
+
+func TestReplayGainTXXX(t *testing.T) {
+	tag := NewTag()
+	tag.SetTextFrame("TXXX:replaygain_track_gain", "+3.21 dB")
+	tag.SetTextFrame("TXXX:replaygain_track_peak", "0.988235")
+	tag.SetTextFrame("TXXX:REPLAYGAIN_ALBUM_GAIN", "-1.50 dB")
+
+	gain, peak, ok := tag.ReplayGainTrack()
+	if !ok || gain != 3.21 || peak != 0.988235 {
+		t.Errorf("got track gain %v, peak %v, ok %t", gain, peak, ok)
+	}
+
+	gain, peak, ok = tag.ReplayGainAlbum()
+	if !ok || gain != -1.5 || peak != 0 {
+		t.Errorf("got album gain %v, peak %v, ok %t", gain, peak, ok)
+	}
+
+	if _, _, ok := NewTag().ReplayGainTrack(); ok {
+		t.Errorf("empty tag reported a track gain")
+	}
+}
+
+func TestReplayGainRVA2(t *testing.T) {
+	// Master volume, -6.5 dB, 16 bit peak of 0.5
+	in := []byte("RVA2\x00\x00\x00\x0c\x00\x00track\x00\x01\xf3\x00\x10\x40\x00")
+	frame := parseFrame(t, in)
+
+	tag := NewTag()
+	tag.Frames["RVA2"] = []Frame{frame}
+	// RVA2 takes precedence over TXXX
+	tag.SetTextFrame("TXXX:replaygain_track_gain", "+3.21 dB")
+
+	gain, peak, ok := tag.ReplayGainTrack()
+	if !ok || gain != -6.5 || peak != 0.5 {
+		t.Errorf("got track gain %v, peak %v, ok %t", gain, peak, ok)
+	}
+
+	tag.SetReplayGainAlbum(2.25, 0.75)
+	if tag.GetTextFrame("TXXX:replaygain_album_gain") != "+2.25 dB" {
+		t.Errorf("unexpected TXXX album gain %q", tag.GetTextFrame("TXXX:replaygain_album_gain"))
+	}
+
+	tag = NewTag()
+	tag.SetReplayGainAlbum(2.25, 0.75)
+	tag.Frames["RVA2"][0] = roundTripFrame(t, tag.Frames["RVA2"][0])
+	delete(tag.Frames, "TXXX")
+	gain, peak, ok = tag.ReplayGainAlbum()
+	if !ok || gain != 2.25 || peak != 0.75 {
+		t.Errorf("got album gain %v, peak %v, ok %t", gain, peak, ok)
+	}
+}
+
+func TestMusicBrainzIDs(t *testing.T) {
+	tests := []struct {
+		get func(*Tag) string
+		set func(*Tag, string)
+		id  string
+	}{
+		{(*Tag).MusicBrainzTrackID, (*Tag).SetMusicBrainzTrackID, "6c2ac6b1-5b2a-4b0e-a4c6-3c1a6a4f5b1d"},
+		{(*Tag).MusicBrainzAlbumID, (*Tag).SetMusicBrainzAlbumID, "0b7e9a3e-8c6f-4f2e-9e0b-1f0a1f5c9d2a"},
+		{(*Tag).MusicBrainzArtistID, (*Tag).SetMusicBrainzArtistID, "5b11f4ce-a62d-471e-81fc-a69a8278c7da"},
+		{(*Tag).MusicBrainzAlbumArtistID, (*Tag).SetMusicBrainzAlbumArtistID, "89ad4ac3-39f7-470e-963a-56509c546377"},
+		{(*Tag).MusicBrainzReleaseGroupID, (*Tag).SetMusicBrainzReleaseGroupID, "1b022e01-4da6-387b-8658-8678046e4cef"},
+	}
+
+	tag := NewTag()
+	for _, test := range tests {
+		test.set(tag, test.id)
+	}
+
+	for _, test := range tests {
+		if got := test.get(tag); got != test.id {
+			t.Errorf("expected ID %q, got %q", test.id, got)
+		}
+	}
+
+	if len(tag.Frames["UFID"]) != 1 || len(tag.Frames["TXXX"]) != 4 {
+		t.Errorf("expected 1 UFID and 4 TXXX frames, got %d and %d",
+			len(tag.Frames["UFID"]), len(tag.Frames["TXXX"]))
+	}
+
+	frame := roundTripFrame(t, tag.Frames["UFID"][0]).(UniqueFileIdentifierFrame)
+	if frame.Owner != "http://musicbrainz.org" || string(frame.Identifier) != tests[0].id {
+		t.Errorf("UFID didn't round-trip, got %q/%q", frame.Owner, frame.Identifier)
+	}
+}
+
+func TestCompilation(t *testing.T) {
+	tag := NewTag()
+	if tag.Compilation() {
+		t.Errorf("absent TCMP frame reported as compilation")
+	}
+
+	tag.SetCompilation(true)
+	if !tag.Compilation() || tag.GetTextFrame("TCMP") != "1" {
+		t.Errorf("expected compilation with TCMP \"1\", got %q", tag.GetTextFrame("TCMP"))
+	}
+
+	tag.SetCompilation(false)
+	if tag.Compilation() || !tag.HasFrame("TCMP") || tag.GetTextFrame("TCMP") != "0" {
+		t.Errorf("expected no compilation with TCMP \"0\", got %q", tag.GetTextFrame("TCMP"))
+	}
+}
+
+func TestSortOrder(t *testing.T) {
+	tag := NewTag()
+	tag.SetAlbumArtistSortOrder("Beatles, The")
+	tag.SetComposerSortOrder("Lennon, John")
+
+	if got := tag.AlbumArtistSortOrder(); got != "Beatles, The" {
+		t.Errorf("unexpected album artist sort order %q", got)
+	}
+	if got := tag.ComposerSortOrder(); got != "Lennon, John" {
+		t.Errorf("unexpected composer sort order %q", got)
+	}
+
+	for _, id := range []FrameType{"TSO2", "TSOC"} {
+		frame := roundTripFrame(t, tag.Frames[id][0])
+		if frame.ID() != id || frame.Value() != tag.GetTextFrame(id) {
+			t.Errorf("%s didn't round-trip, got %s = %q", id, frame.ID(), frame.Value())
+		}
+	}
+}
+
+func TestInvolvedPeople(t *testing.T) {
+	tag := NewTag()
+	tag.SetTextFrame("TIPL", "producer\x00Alice\x00mix\x00Bob")
+	tag.SetTextFrame("TMCL", "guitar\x00Carol\x00drums")
+
+	people := tag.InvolvedPeople()
+	expected := []InvolvedPerson{{"producer", "Alice"}, {"mix", "Bob"}}
+	if len(people) != len(expected) || people[0] != expected[0] || people[1] != expected[1] {
+		t.Errorf("expected %v, got %v", expected, people)
+	}
+
+	credits := tag.MusicianCredits()
+	if len(credits) != 1 || credits[0] != (InvolvedPerson{"guitar", "Carol"}) {
+		t.Errorf("expected the unpaired entry to be dropped, got %v", credits)
+	}
+
+	tag.SetMusicianCredits(expected)
+	if got := tag.GetTextFrame("TMCL"); got != "producer\x00Alice\x00mix\x00Bob" {
+		t.Errorf("unexpected TMCL %q", got)
+	}
+}
+
+func TestComment(t *testing.T) {
+	tag := NewTag()
+	tag.SetComment(Comment{Language: "eng", Description: "", Text: "main comment"})
+	tag.SetComment(Comment{Language: "eng", Description: "iTunNORM", Text: " 00000A1B 00000B2C"})
+	tag.SetComment(Comment{Language: "eng", Description: "", Text: "new main comment"})
+
+	if n := len(tag.Comments()); n != 2 {
+		t.Fatalf("expected 2 comments, got %d", n)
+	}
+
+	comment, ok := tag.Comment("eng", "")
+	if !ok || comment.Text != "new main comment" {
+		t.Errorf("unexpected main comment %q (ok = %t)", comment.Text, ok)
+	}
+
+	comment, ok = tag.Comment("eng", "iTunNORM")
+	if !ok || comment.Text != " 00000A1B 00000B2C" {
+		t.Errorf("unexpected iTunNORM comment %q (ok = %t)", comment.Text, ok)
+	}
+
+	if _, ok := tag.Comment("deu", ""); ok {
+		t.Errorf("found comment for wrong language")
+	}
+}
+
+func TestCommentLanguageNormalization(t *testing.T) {
+	tag := NewTag()
+	tag.SetComment(Comment{Language: "en", Text: "first"})
+	if c, ok := tag.Comment("en", ""); !ok || c.Text != "first" {
+		t.Errorf("expected to find comment by two-letter code, got %v, %t", c, ok)
+	}
+	if c, ok := tag.Comment("ENG", ""); !ok || c.Text != "first" {
+		t.Errorf("expected to find comment by upper case code, got %v, %t", c, ok)
+	}
+
+	tag.Frames["COMM"] = []Frame{CommentFrame{
+		FrameHeader: FrameHeader{id: "COMM"},
+		Language:    "ENG",
+		Text:        "old",
+	}}
+	tag.SetComment(Comment{Language: "eng", Text: "new"})
+	comments := tag.Comments()
+	if len(comments) != 1 || comments[0].Text != "new" {
+		t.Errorf("expected the existing comment to be replaced, got %v", comments)
+	}
+}
+
+func TestCommentFrameRoundTrip(t *testing.T) {
+	tests := []struct {
+		in  CommentFrame
+		out Comment
+	}{
+		{
+			CommentFrame{Language: "eng", Description: "note", Text: "hello"},
+			Comment{Language: "eng", Description: "note", Text: "hello"},
+		},
+		{
+			CommentFrame{Language: "eng", Description: "", Text: "hello"},
+			Comment{Language: "eng", Description: "", Text: "hello"},
+		},
+		{
+			CommentFrame{Language: "en", Description: "note", Text: "hello"},
+			Comment{Language: "XXX", Description: "note", Text: "hello"},
+		},
+	}
+
+	for _, test := range tests {
+		test.in.FrameHeader = FrameHeader{id: "COMM"}
+		frame := roundTripFrame(t, test.in).(CommentFrame)
+		got := Comment{Language: frame.Language, Description: frame.Description, Text: frame.Text}
+		if got != test.out {
+			t.Errorf("expected %q, got %q", test.out, got)
+		}
+	}
+}
+
+func TestPreserveFrameFlags(t *testing.T) {
+	// TIT2 with the read only flag set
+	in := []byte("TIT2\x00\x00\x00\x04\x10\x00\x03abc")
+	frame := parseFrame(t, in)
+	if !frame.Header().flags.ReadOnly() {
+		t.Fatalf("read only flag wasn't parsed, got flags %#x", frame.Header().flags)
+	}
+
+	tag := NewTag()
+	tag.Frames["TIT2"] = []Frame{frame}
+	tag.SetTitle("def")
+
+	frame = roundTripFrame(t, tag.Frames["TIT2"][0])
+	if !frame.Header().flags.ReadOnly() || frame.Value() != "def" {
+		t.Errorf("expected read only frame with value \"def\", got flags %#x and value %q",
+			frame.Header().flags, frame.Value())
+	}
+}
+
+func TestFrameHeaderFlags(t *testing.T) {
+	// PRIV frame that should be discarded on file alteration
+	in := []byte("PRIV\x00\x00\x00\x05\x20\x00own\x00x")
+	frame := parseFrame(t, in)
+
+	flags := frame.Header().Flags()
+	if flags != 0x2000 || flags.PreserveFileAlteration() || !flags.PreserveTagAlteration() {
+		t.Errorf("unexpected flags %s", flags)
+	}
+	if frame.Size() != len(in) {
+		t.Errorf("expected size %d, got %d", len(in), frame.Size())
+	}
+}
+
+func TestUnsupportedFrameRoundTrip(t *testing.T) {
+	tests := [][]byte{
+		// Unknown frame
+		[]byte("XPRV\x00\x00\x00\x06\x00\x00own\x00\x01\x02"),
+		// Grouped frame with group symbol 0x05
+		[]byte("XPRV\x00\x00\x00\x05\x00\x40\x05data"),
+		// Compressed known frame with data length indicator
+		[]byte("TIT2\x00\x00\x00\x07\x00\x09\x00\x00\x00\x10xyz"),
+	}
+
+	for _, in := range tests {
+		frame := parseFrame(t, in)
+		if _, ok := frame.(UnsupportedFrame); !ok {
+			t.Errorf("expected UnsupportedFrame, got %T", frame)
+			continue
+		}
+
+		buf := &bytes.Buffer{}
+		if err := NewEncoder(buf).WriteFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), in) {
+			t.Errorf("expected %q, got %q", in, buf.Bytes())
+		}
+	}
+}
+
+func TestUpgradeFrameData(t *testing.T) {
+	// compressed, encrypted and grouped
+	in := []byte("\x00\x00\x01\x00\x07\x09data")
+	out := []byte("\x09\x07\x00\x00\x02\x00data")
+	if got := upgradeFrameData(0x00E0, in); !bytes.Equal(got, out) {
+		t.Errorf("expected %q, got %q", out, got)
+	}
+}
+
+func TestDiscardOnFileAlteration(t *testing.T) {
+	tag := NewTag()
+	tag.Frames["XPRV"] = []Frame{UnsupportedFrame{
+		FrameHeader: FrameHeader{id: "XPRV", flags: 0x2000},
+		Data:        []byte("data"),
+	}}
+
+	for _, altered := range []bool{false, true} {
+		buf := &bytes.Buffer{}
+		enc := NewEncoder(buf)
+		enc.FileAltered = altered
+		if err := enc.WriteTag(tag); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := bytes.Contains(buf.Bytes(), []byte("XPRV")); got == altered {
+			t.Errorf("FileAltered = %t, but frame written = %t", altered, got)
+		}
+	}
+}
+
+func TestTagAltered(t *testing.T) {
+	tag := NewTag()
+	tag.Frames["XPRV"] = []Frame{UnsupportedFrame{
+		FrameHeader: FrameHeader{id: "XPRV", flags: 0x2000},
+		Data:        []byte("data"),
+	}}
+	tag.Frames["XTAG"] = []Frame{UnsupportedFrame{
+		FrameHeader: FrameHeader{id: "XTAG", flags: 0x4000},
+		Data:        []byte("data"),
+	}}
+	tag.Frames["XKEP"] = []Frame{UnsupportedFrame{
+		FrameHeader: FrameHeader{id: "XKEP"},
+		Data:        []byte("data"),
+	}}
+
+	for _, fileAltered := range []bool{false, true} {
+		for _, tagAltered := range []bool{false, true} {
+			buf := &bytes.Buffer{}
+			enc := NewEncoder(buf)
+			enc.FileAltered = fileAltered
+			enc.TagAltered = tagAltered
+			if err := enc.WriteTag(tag); err != nil {
+				t.Fatal(err)
+			}
+
+			want := map[string]bool{
+				"XPRV": !fileAltered,
+				"XTAG": !tagAltered,
+				"XKEP": true,
+			}
+			for id, written := range want {
+				if got := bytes.Contains(buf.Bytes(), []byte(id)); got != written {
+					t.Errorf("FileAltered = %t, TagAltered = %t: expected %s written = %t, got %t",
+						fileAltered, tagAltered, id, written, got)
+				}
+			}
+		}
+	}
+}
+
+func TestUpgradeFrameFlags(t *testing.T) {
+	tests := []struct {
+		in  FrameFlags
+		out FrameFlags
+	}{
+		{0x0000, 0x0000},
+		{0x8000, 0x4000},
+		{0x4000, 0x2000},
+		{0x2000, 0x1000},
+		{0x0080, 0x0009},
+		{0x0040, 0x0004},
+		{0x0020, 0x0040},
+	}
+
+	for _, test := range tests {
+		if got := upgradeFrameFlags(test.in); got != test.out {
+			t.Errorf("expected %#04x to upgrade to %#04x, got %#04x", test.in, test.out, got)
+		}
+	}
+}
+
+func TestFlagsString(t *testing.T) {
+	tests := []struct {
+		in  fmt.Stringer
+		out string
+	}{
+		{HeaderFlags(0), "none"},
+		{HeaderFlags(128 | 64), "Unsynchronisation|ExtendedHeader"},
+		{HeaderFlags(32 | 1), "Experimental|0x1"},
+		{FrameFlags(0), "none"},
+		{FrameFlags(0x0008 | 0x0040 | 0x1000), "Compressed|Grouped|ReadOnly"},
+		{FrameFlags(0x4000 | 0x0001), "DiscardOnTagAlteration|DataLengthIndicator"},
+	}
+
+	for _, test := range tests {
+		if got := test.in.String(); got != test.out {
+			t.Errorf("expected %q, got %q", test.out, got)
+		}
+	}
+}
+
+func TestEventTimingFrame(t *testing.T) {
+	tag := NewTag()
+	tag.SetEventTimings(Milliseconds, []TimingEvent{
+		{Type: 0x02, Timestamp: 1500},
+		{Type: 0x03, Timestamp: 0x01020304},
+	})
+	tag.Frames["ETCO"][0] = roundTripFrame(t, tag.Frames["ETCO"][0])
+
+	format, events := tag.EventTimings()
+	if format != Milliseconds {
+		t.Errorf("expected timestamp format %d, got %d", Milliseconds, format)
+	}
+	if len(events) != 2 ||
+		events[0] != (TimingEvent{0x02, 1500}) ||
+		events[1] != (TimingEvent{0x03, 0x01020304}) {
+		t.Errorf("unexpected events %v", events)
+	}
+}
+
+func TestSynchronisedLyricsFrame(t *testing.T) {
+	in := SynchronisedLyricsFrame{
+		FrameHeader:     FrameHeader{id: "SYLT"},
+		Language:        "deu",
+		TimestampFormat: Milliseconds,
+		ContentType:     1,
+		Description:     "Strophe",
+		Lines: []SyncedText{
+			{Text: "Strophe eins", Timestamp: 1000},
+			{Text: "Zweite Strophe ü", Timestamp: 4000},
+		},
+	}
+
+	out := roundTripFrame(t, in).(SynchronisedLyricsFrame)
+	if out.Language != in.Language || out.TimestampFormat != in.TimestampFormat ||
+		out.ContentType != in.ContentType || out.Description != in.Description {
+		t.Errorf("expected %+v, got %+v", in, out)
+	}
+	if len(out.Lines) != 2 || out.Lines[0] != in.Lines[0] || out.Lines[1] != in.Lines[1] {
+		t.Errorf("expected lines %v, got %v", in.Lines, out.Lines)
+	}
+
+	// UTF-16 with BOM; the description is "d" and the only line is
+	// "\u0100" at 2 ms.
+	raw := []byte("SYLT\x00\x00\x00\x16\x00\x00\x01eng\x02\x01" +
+		"\xff\xfed\x00\x00\x00" +
+		"\xfe\xff\x01\x00\x00\x00\x00\x00\x00\x02")
+	frame := parseFrame(t, raw).(SynchronisedLyricsFrame)
+	if frame.Description != "d" || len(frame.Lines) != 1 ||
+		frame.Lines[0] != (SyncedText{"\u0100", 2}) {
+		t.Errorf("unexpected UTF-16 frame %+v", frame)
+	}
+
+	tag := NewTag()
+	tag.Frames["SYLT"] = []Frame{in, frame}
+	if n := len(tag.SynchronisedLyrics()); n != 2 {
+		t.Errorf("expected 2 synchronised lyrics, got %d", n)
+	}
+}
+
+func TestAudioSeekPointIndexFrame(t *testing.T) {
+	for _, bits := range []byte{8, 16} {
+		in := AudioSeekPointIndexFrame{
+			FrameHeader:  FrameHeader{id: "ASPI"},
+			DataStart:    1024,
+			DataLength:   0x00ABCDEF,
+			BitsPerPoint: bits,
+			Points:       []uint16{1, 200, 255},
+		}
+		if bits == 16 {
+			in.Points[2] = 0x1234
+		}
+
+		out := roundTripFrame(t, in).(AudioSeekPointIndexFrame)
+		if out.DataStart != in.DataStart || out.DataLength != in.DataLength ||
+			out.BitsPerPoint != bits || len(out.Points) != 3 {
+			t.Fatalf("expected %+v, got %+v", in, out)
+		}
+		for i := range in.Points {
+			if out.Points[i] != in.Points[i] {
+				t.Errorf("expected points %v, got %v", in.Points, out.Points)
+				break
+			}
+		}
+	}
+}
+
+func TestOwnershipFrame(t *testing.T) {
+	in := OwnershipFrame{
+		FrameHeader:   FrameHeader{id: "OWNE"},
+		PricePaid:     "EUR9.99",
+		DatePurchased: "20140321",
+		Seller:        "Plattenladen Müller",
+	}
+
+	out := roundTripFrame(t, in).(OwnershipFrame)
+	if out != in {
+		t.Errorf("expected %+v, got %+v", in, out)
+	}
+
+	// ISO-8859-1 encoded seller
+	raw := []byte("OWNE\x00\x00\x00\x24\x00\x00\x00EUR9.99\x0020140321Plattenladen M\xfcller")
+	out = parseFrame(t, raw).(OwnershipFrame)
+	if out != in {
+		t.Errorf("expected %+v, got %+v", in, out)
+	}
+}
+
+func TestCommercialFrame(t *testing.T) {
+	in := CommercialFrame{
+		FrameHeader: FrameHeader{id: "COMR"},
+		Price:       "EUR9.99/USD10.99",
+		ValidUntil:  "20151231",
+		ContactURL:  "http://example.com/buy",
+		ReceivedAs:  0x02,
+		Seller:      "Plattenladen Müller",
+		Description: "Album download",
+	}
+
+	tag := NewTag()
+	tag.Frames["COMR"] = []Frame{roundTripFrame(t, in)}
+	out := tag.Commercials()
+	if len(out) != 1 {
+		t.Fatalf("expected 1 commercial frame, got %d", len(out))
+	}
+	if out[0].Price != in.Price || out[0].ValidUntil != in.ValidUntil ||
+		out[0].ContactURL != in.ContactURL || out[0].ReceivedAs != in.ReceivedAs ||
+		out[0].Seller != in.Seller || out[0].Description != in.Description ||
+		out[0].LogoMIMEType != "" || len(out[0].Logo) != 0 {
+		t.Errorf("expected %+v, got %+v", in, out[0])
+	}
+
+	in.LogoMIMEType = "image/png"
+	in.Logo = []byte("\x89PNG\x00\x01")
+	frame := roundTripFrame(t, in).(CommercialFrame)
+	if frame.LogoMIMEType != in.LogoMIMEType || !bytes.Equal(frame.Logo, in.Logo) {
+		t.Errorf("expected logo %q/%q, got %q/%q", in.LogoMIMEType, in.Logo, frame.LogoMIMEType, frame.Logo)
+	}
+}
+
+func TestPositionSyncFrame(t *testing.T) {
+	tag := NewTag()
+	if _, _, ok := tag.PositionSync(); ok {
+		t.Errorf("empty tag reported a position")
+	}
+
+	tag.SetPositionSync(MPEGFrames, 0xDEADBEEF)
+	tag.Frames["POSS"][0] = roundTripFrame(t, tag.Frames["POSS"][0])
+
+	format, pos, ok := tag.PositionSync()
+	if !ok || format != MPEGFrames || pos != 0xDEADBEEF {
+		t.Errorf("expected %d/%#x, got %d/%#x (ok = %t)", MPEGFrames, 0xDEADBEEF, format, pos, ok)
+	}
+}
+
+func TestRecommendedBufferFrame(t *testing.T) {
+	tests := []RecommendedBufferFrame{
+		{BufferSize: 0x123456, EmbeddedInfo: true},
+		{BufferSize: 4096, EmbeddedInfo: false, NextFlagOffset: 0x01020304},
+	}
+
+	for _, in := range tests {
+		in.FrameHeader = FrameHeader{id: "RBUF"}
+		out := roundTripFrame(t, in).(RecommendedBufferFrame)
+		if out != in {
+			t.Errorf("expected %+v, got %+v", in, out)
+		}
+	}
+}
+
+func TestMPEGLookupTableFrame(t *testing.T) {
+	in := MPEGLookupTableFrame{
+		FrameHeader:                  FrameHeader{id: "MLLT"},
+		FramesBetweenReference:       10,
+		BytesBetweenReference:        4180,
+		MillisecondsBetweenReference: 261,
+		BitsForBytesDeviation:        12,
+		BitsForMillisecondsDeviation: 4,
+		References: []MPEGLookupReference{
+			{BytesDeviation: 0xABC, MillisecondsDeviation: 0x5},
+			{BytesDeviation: 0x001, MillisecondsDeviation: 0xF},
+		},
+	}
+
+	buf := in.Encode()
+	if !bytes.Equal(buf[10:], []byte{0xAB, 0xC5, 0x00, 0x1F}) {
+		t.Errorf("unexpected packed references %x", buf[10:])
+	}
+
+	out := roundTripFrame(t, in).(MPEGLookupTableFrame)
+	if out.FramesBetweenReference != in.FramesBetweenReference ||
+		out.BytesBetweenReference != in.BytesBetweenReference ||
+		out.MillisecondsBetweenReference != in.MillisecondsBetweenReference ||
+		out.BitsForBytesDeviation != in.BitsForBytesDeviation ||
+		out.BitsForMillisecondsDeviation != in.BitsForMillisecondsDeviation {
+		t.Errorf("expected %+v, got %+v", in, out)
+	}
+	if len(out.References) != 2 || out.References[0] != in.References[0] || out.References[1] != in.References[1] {
+		t.Errorf("expected references %v, got %v", in.References, out.References)
+	}
+
+	// Widths that aren't multiples of 8 need padding
+	in.BitsForBytesDeviation = 7
+	in.BitsForMillisecondsDeviation = 3
+	in.References = []MPEGLookupReference{{0x7F, 0x1}, {0x2A, 0x6}}
+	out = roundTripFrame(t, in).(MPEGLookupTableFrame)
+	if len(out.References) != 2 || out.References[0] != in.References[0] || out.References[1] != in.References[1] {
+		t.Errorf("expected references %v, got %v", in.References, out.References)
+	}
+}
+
+func TestMPEGLookupTableFrameInvalidBits(t *testing.T) {
+	for _, bits := range []string{"\x00\x00", "\x21\x04", "\x04\x21"} {
+		b := []byte("MLLT\x00\x00\x00\x0A\x00\x00" +
+			"\x00\x0A\x00\x10\x54\x00\x01\x05" + bits +
+			"TPE1\x00\x00\x00\x07\x00\x00\x03Artist")
+		d := NewDecoder(bytes.NewReader(b))
+		d.r = io.LimitReader(d.r, int64(len(b)))
+		d.h.Version = 0x0400
+		frame, err := d.ParseFrame()
+		if err != nil {
+			t.Fatalf("%x: %s", bits, err)
+		}
+		if frame.ID() != "TPE1" {
+			t.Errorf("%x: expected MLLT frame to be dropped, got %s", bits, frame.ID())
+		}
+		want := DroppedFrameError{"MLLT", "invalid deviation bit counts"}
+		if w := d.Warnings(); len(w) != 1 || w[0] != want {
+			t.Errorf("%x: expected warning %v, got %v", bits, want, w)
+		}
+	}
+}
+
+func TestRegistrationFrames(t *testing.T) {
+	grid := GroupRegistrationFrame{
+		FrameHeader: FrameHeader{id: "GRID"},
+		Owner:       "mailto:groups@example.com",
+		GroupSymbol: 0x80,
+		Data:        []byte{0x00, 0x01, 0x02},
+	}
+	out := roundTripFrame(t, grid).(GroupRegistrationFrame)
+	if out.Owner != grid.Owner || out.GroupSymbol != grid.GroupSymbol || !bytes.Equal(out.Data, grid.Data) {
+		t.Errorf("expected %+v, got %+v", grid, out)
+	}
+
+	encr := EncryptionRegistrationFrame{
+		FrameHeader:  FrameHeader{id: "ENCR"},
+		Owner:        "http://example.com/drm",
+		MethodSymbol: 0x81,
+	}
+	out2 := roundTripFrame(t, encr).(EncryptionRegistrationFrame)
+	if out2.Owner != encr.Owner || out2.MethodSymbol != encr.MethodSymbol || len(out2.Data) != 0 {
+		t.Errorf("expected %+v, got %+v", encr, out2)
+	}
+}
+
+func TestLinkFrame(t *testing.T) {
+	in := LinkFrame{
+		FrameHeader:     FrameHeader{id: "LINK"},
+		FrameIdentifier: [4]byte{'T', 'A', 'L', 'B'},
+		URL:             "http://example.com/album.mp3",
+	}
+
+	tag := NewTag()
+	tag.Frames["LINK"] = []Frame{roundTripFrame(t, in)}
+	links := tag.LinkedInformation()
+	if len(links) != 1 {
+		t.Fatalf("expected 1 link, got %d", len(links))
+	}
+	if links[0].FrameIdentifier != in.FrameIdentifier || links[0].URL != in.URL || len(links[0].IDAndData) != 0 {
+		t.Errorf("expected %+v, got %+v", in, links[0])
+	}
+
+	in.FrameIdentifier = [4]byte{'C', 'O', 'M', 'M'}
+	in.IDAndData = []byte("engnote")
+	out := roundTripFrame(t, in).(LinkFrame)
+	if out.FrameIdentifier != in.FrameIdentifier || !bytes.Equal(out.IDAndData, in.IDAndData) {
+		t.Errorf("expected %+v, got %+v", in, out)
+	}
+}
+
+func TestReverbFrame(t *testing.T) {
+	in := ReverbFrame{
+		FrameHeader:        FrameHeader{id: "RVRB"},
+		Left:               0x0102,
+		Right:              0x0304,
+		BouncesLeft:        5,
+		BouncesRight:       6,
+		FeedbackLeftLeft:   7,
+		FeedbackLeftRight:  8,
+		FeedbackRightRight: 9,
+		FeedbackRightLeft:  10,
+		PremixLeftRight:    11,
+		PremixRightLeft:    12,
+	}
+
+	if b := in.Encode(); !bytes.Equal(b, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}) {
+		t.Errorf("unexpected encoding %v", b)
+	}
+
+	out := roundTripFrame(t, in).(ReverbFrame)
+	if out != in {
+		t.Errorf("expected %+v, got %+v", in, out)
+	}
+}
+
+func TestAudioEncryptionFrame(t *testing.T) {
+	in := AudioEncryptionFrame{
+		FrameHeader:    FrameHeader{id: "AENC"},
+		Owner:          "mailto:drm@example.com",
+		PreviewStart:   0x0102,
+		PreviewLength:  0x0304,
+		EncryptionInfo: []byte{0x00, 0xFF, 0x10},
+	}
+
+	tag := NewTag()
+	tag.Frames["AENC"] = []Frame{roundTripFrame(t, in)}
+	out := tag.AudioEncryption()
+	if len(out) != 1 {
+		t.Fatalf("expected 1 AENC frame, got %d", len(out))
+	}
+	if out[0].Owner != in.Owner || out[0].PreviewStart != in.PreviewStart ||
+		out[0].PreviewLength != in.PreviewLength || !bytes.Equal(out[0].EncryptionInfo, in.EncryptionInfo) {
+		t.Errorf("expected %+v, got %+v", in, out[0])
+	}
+}
+
+func TestSignatureFrame(t *testing.T) {
+	tag := NewTag()
+	for i, sig := range [][]byte{{0xDE, 0xAD, 0xBE, 0xEF}, {0x00}} {
+		in := SignatureFrame{
+			FrameHeader: FrameHeader{id: "SIGN"},
+			GroupSymbol: byte(0x80 + i),
+			Signature:   sig,
+		}
+		tag.Frames["SIGN"] = append(tag.Frames["SIGN"], roundTripFrame(t, in))
+	}
+
+	sigs := tag.Signatures()
+	if len(sigs) != 2 {
+		t.Fatalf("expected 2 signatures, got %d", len(sigs))
+	}
+	if sigs[0].GroupSymbol != 0x80 || !bytes.Equal(sigs[0].Signature, []byte{0xDE, 0xAD, 0xBE, 0xEF}) {
+		t.Errorf("unexpected first signature %+v", sigs[0])
+	}
+	if sigs[1].GroupSymbol != 0x81 || !bytes.Equal(sigs[1].Signature, []byte{0x00}) {
+		t.Errorf("unexpected second signature %+v", sigs[1])
+	}
+}
+
+func TestUserURL(t *testing.T) {
+	tag := NewTag()
+	tag.SetUserURL("Discogs", "http://www.discogs.com/release/1")
+	tag.SetUserURL("Wikipedia", "http://en.wikipedia.org/wiki/Album")
+	tag.SetUserURL("Discogs", "http://www.discogs.com/release/2")
+
+	if n := len(tag.Frames["WXXX"]); n != 2 {
+		t.Fatalf("expected 2 WXXX frames, got %d", n)
+	}
+	for i, frame := range tag.Frames["WXXX"] {
+		tag.Frames["WXXX"][i] = roundTripFrame(t, frame)
+	}
+
+	if got := tag.UserURL("Discogs"); got != "http://www.discogs.com/release/2" {
+		t.Errorf("unexpected Discogs URL %q", got)
+	}
+	if got := tag.UserURL("Wikipedia"); got != "http://en.wikipedia.org/wiki/Album" {
+		t.Errorf("unexpected Wikipedia URL %q", got)
+	}
+	if got := tag.UserURL("Amazon"); got != "" {
+		t.Errorf("unexpected Amazon URL %q", got)
+	}
+}
+
+func TestURLFrames(t *testing.T) {
+	single := []struct {
+		get func(*Tag) string
+		set func(*Tag, string)
+		id  FrameType
+	}{
+		{(*Tag).CopyrightURL, (*Tag).SetCopyrightURL, "WCOP"},
+		{(*Tag).AudioFileURL, (*Tag).SetAudioFileURL, "WOAF"},
+		{(*Tag).AudioSourceURL, (*Tag).SetAudioSourceURL, "WOAS"},
+		{(*Tag).RadioStationURL, (*Tag).SetRadioStationURL, "WORS"},
+		{(*Tag).PaymentURL, (*Tag).SetPaymentURL, "WPAY"},
+		{(*Tag).PublisherURL, (*Tag).SetPublisherURL, "WPUB"},
+	}
+
+	tag := NewTag()
+	for _, test := range single {
+		url := "http://example.com/" + string(test.id)
+		test.set(tag, "http://example.com/old")
+		test.set(tag, url)
+		if len(tag.Frames[test.id]) != 1 {
+			t.Errorf("expected 1 %s frame, got %d", test.id, len(tag.Frames[test.id]))
+		}
+		tag.Frames[test.id][0] = roundTripFrame(t, tag.Frames[test.id][0])
+		if got := test.get(tag); got != url {
+			t.Errorf("expected %s to be %q, got %q", test.id, url, got)
+		}
+	}
+
+	urls := []string{"http://example.com/artist", "http://example.org/artist"}
+	tag.SetArtistURLs(urls)
+	if got := tag.ArtistURLs(); len(got) != 2 || got[0] != urls[0] || got[1] != urls[1] {
+		t.Errorf("expected artist URLs %q, got %q", urls, got)
+	}
+	if got := tag.ArtistURL(); got != urls[0] {
+		t.Errorf("expected artist URL %q, got %q", urls[0], got)
+	}
+
+	tag.SetArtistURL(urls[1])
+	if got := tag.ArtistURLs(); len(got) != 1 || got[0] != urls[1] {
+		t.Errorf("expected artist URLs %q, got %q", urls[1:], got)
+	}
+
+	tag.SetCommercialURLs(urls)
+	if got := tag.CommercialURLs(); len(got) != 2 {
+		t.Errorf("expected 2 commercial URLs, got %q", got)
+	}
+}
+
+func TestUniqueFileID(t *testing.T) {
+	binaryID := make([]byte, 64)
+	for i := range binaryID {
+		binaryID[i] = byte(i * 4)
+	}
+
+	tag := NewTag()
+	tag.SetUniqueFileID("http://www.id3.org/dummy/ufid.html", binaryID)
+	tag.SetUniqueFileID("mailto:ids@example.com", []byte("42"))
+
+	for i, frame := range tag.Frames["UFID"] {
+		tag.Frames["UFID"][i] = roundTripFrame(t, frame)
+	}
+
+	id, ok := tag.UniqueFileID("http://www.id3.org/dummy/ufid.html")
+	if !ok || !bytes.Equal(id, binaryID) {
+		t.Errorf("expected %x, got %x (ok = %t)", binaryID, id, ok)
+	}
+	id, ok = tag.UniqueFileID("mailto:ids@example.com")
+	if !ok || string(id) != "42" {
+		t.Errorf("expected \"42\", got %q (ok = %t)", id, ok)
+	}
+	if _, ok := tag.UniqueFileID("http://musicbrainz.org"); ok {
+		t.Errorf("found UFID of unknown owner")
+	}
+}
+
+func TestTermsOfUse(t *testing.T) {
+	tag := NewTag()
+	tag.SetTermsOfUse("deu", "Vervielfältigung verboten")
+	tag.SetTermsOfUse("eng", "No copying")
+	tag.Frames["USER"][0] = roundTripFrame(t, tag.Frames["USER"][0])
+
+	if got := tag.TermsOfUse("deu"); got != "Vervielfältigung verboten" {
+		t.Errorf("unexpected German terms of use %q", got)
+	}
+	if got := tag.TermsOfUse("eng"); got != "No copying" {
+		t.Errorf("unexpected English terms of use %q", got)
+	}
+
+	// ISO-8859-1 encoded
+	frame := parseFrame(t, []byte("USER\x00\x00\x00\x07\x00\x00\x00deu\xe4\xf6\xfc")).(TermsOfUseFrame)
+	if frame.Language != "deu" || frame.Text != "äöü" {
+		t.Errorf("unexpected frame %+v", frame)
+	}
+}
+
+func TestTextFrameAccessors(t *testing.T) {
+	tests := []struct {
+		get func(*Tag) string
+		set func(*Tag, string)
+		id  FrameType
+	}{
+		{(*Tag).Copyright, (*Tag).SetCopyright, "TCOP"},
+		{(*Tag).EncodedBy, (*Tag).SetEncodedBy, "TENC"},
+		{(*Tag).MediaType, (*Tag).SetMediaType, "TMED"},
+		{(*Tag).FileType, (*Tag).SetFileType, "TFLT"},
+		{(*Tag).InitialKey, (*Tag).SetInitialKey, "TKEY"},
+		{(*Tag).ContentGroup, (*Tag).SetContentGroup, "TIT1"},
+		{(*Tag).Subtitle, (*Tag).SetSubtitle, "TIT3"},
+		{(*Tag).DiscSubtitle, (*Tag).SetDiscSubtitle, "TSST"},
+		{(*Tag).ProducedNotice, (*Tag).SetProducedNotice, "TPRO"},
+		{(*Tag).EncodingSoftware, (*Tag).SetEncodingSoftware, "TSSE"},
+		{(*Tag).Remixer, (*Tag).SetRemixer, "TPE4"},
+		{(*Tag).Lyricist, (*Tag).SetLyricist, "TEXT"},
+		{(*Tag).OriginalLyricist, (*Tag).SetOriginalLyricist, "TOLY"},
+		{(*Tag).OriginalAlbum, (*Tag).SetOriginalAlbum, "TOAL"},
+	}
+
+	tag := NewTag()
+	for _, test := range tests {
+		value := "value of " + string(test.id)
+		test.set(tag, value)
+		if got := tag.GetTextFrame(test.id); got != value {
+			t.Errorf("setter wrote %q to %s, expected %q", got, test.id, value)
+		}
+		if got := test.get(tag); got != value {
+			t.Errorf("getter for %s returned %q, expected %q", test.id, got, value)
+		}
+	}
+
+	times := []struct {
+		get func(*Tag) time.Time
+		set func(*Tag, time.Time)
+		id  FrameType
+	}{
+		{(*Tag).ReleaseTime, (*Tag).SetReleaseTime, "TDRL"},
+		{(*Tag).TaggingTime, (*Tag).SetTaggingTime, "TDTG"},
+	}
+
+	for _, test := range times {
+		value := time.Date(2009, 11, 10, 23, 1, 2, 0, time.UTC)
+		test.set(tag, value)
+		if got := tag.GetTextFrame(test.id); got != "2009-11-10T23:01:02" {
+			t.Errorf("setter wrote %q to %s", got, test.id)
+		}
+		if got := test.get(tag); !got.Equal(value) {
+			t.Errorf("getter for %s returned %s, expected %s", test.id, got, value)
+		}
+	}
+
+	tag.SetLyricists([]string{"a", "b"})
+	if got := tag.GetTextFrame("TEXT"); got != "a\x00b" {
+		t.Errorf("unexpected TEXT %q", got)
+	}
+}
+
+func TestTextFrameValues(t *testing.T) {
+	tag := NewTag()
+	tag.RemoveTextFrameValue("TPE1", "a")
+	if tag.HasFrame("TPE1") {
+		t.Errorf("removing from an absent frame created it")
+	}
+
+	tag.AddTextFrameValue("TPE1", "a")
+	tag.AddTextFrameValue("TPE1", "b")
+	tag.AddTextFrameValue("TPE1", "c")
+	if got := tag.GetTextFrame("TPE1"); got != "a\x00b\x00c" {
+		t.Errorf("unexpected TPE1 %q", got)
+	}
+
+	tag.RemoveTextFrameValue("TPE1", "b")
+	tag.RemoveTextFrameValue("TPE1", "d")
+	if got := tag.GetTextFrame("TPE1"); got != "a\x00c" {
+		t.Errorf("unexpected TPE1 %q", got)
+	}
+
+	tag.RemoveTextFrameValue("TPE1", "a")
+	tag.RemoveTextFrameValue("TPE1", "c")
+	if tag.HasFrame("TPE1") {
+		t.Errorf("removing all values didn't remove the frame")
+	}
+
+	tag.SetTextFrame("TXXX:Other", "x")
+	tag.AddTextFrameValue("TXXX:Key", "a")
+	tag.RemoveTextFrameValue("TXXX:Key", "a")
+	if frames := tag.UserTextFrames(); len(frames) != 1 || frames[0].Description != "Other" {
+		t.Errorf("removing all values didn't remove the TXXX frame, got %v", frames)
+	}
+}
+
+// mp3Frames returns n silent MPEG-1 layer III frames at 128 kbit/s
+// and 44.1 kHz, each lasting 1152/44100 seconds.
+func mp3Frames(n int) []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+
+	return bytes.Repeat(frame, n)
+}
+
+func TestAudioLength(t *testing.T) {
+	frameDuration := 1152.0 / 44100 * float64(time.Second)
+
+	// CBR, with leading junk
+	audio := append([]byte("junk"), mp3Frames(100)...)
+	d, err := AudioLength(bytes.NewReader(audio))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := time.Duration(100 * frameDuration); d < expected-time.Millisecond || d > expected+time.Millisecond {
+		t.Errorf("expected CBR length %s, got %s", expected, d)
+	}
+
+	// VBR with a Xing header claiming 1000 frames
+	audio = mp3Frames(10)
+	copy(audio[4+32:], []byte("Xing\x00\x00\x00\x01\x00\x00\x03\xe8"))
+	d, err = AudioLength(bytes.NewReader(audio))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := time.Duration(1000 * frameDuration); d < expected-time.Millisecond || d > expected+time.Millisecond {
+		t.Errorf("expected VBR length %s, got %s", expected, d)
+	}
+
+	if _, err := AudioLength(strings.NewReader("not audio")); err != ErrNoAudio {
+		t.Errorf("expected ErrNoAudio, got %v", err)
+	}
+
+	tag := NewTag()
+	tag.SetLength(5 * time.Second)
+	d, err = tag.LengthFrom(bytes.NewReader(mp3Frames(100)))
+	if err != nil || d != 5*time.Second {
+		t.Errorf("expected TLEN to take precedence, got %s (err = %v)", d, err)
+	}
+}
+
+func TestParseReaderAt(t *testing.T) {
+	tag := NewTag()
+	tag.SetTitle("Title")
+	tag.SetArtist("Artist")
+
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	enc.Padding = 100
+	if err := enc.WriteTag(tag); err != nil {
+		t.Fatal(err)
+	}
+	size := int64(buf.Len())
+	buf.WriteString("audio")
+
+	r := bytes.NewReader(buf.Bytes())
+	out, offset, err := ParseReaderAt(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != size {
+		t.Errorf("expected audio at offset %d, got %d", size, offset)
+	}
+	if out.Title() != "Title" || out.Artist() != "Artist" {
+		t.Errorf("unexpected title %q and artist %q", out.Title(), out.Artist())
+	}
+
+	audio := make([]byte, 5)
+	if _, err := r.ReadAt(audio, offset); err != nil || string(audio) != "audio" {
+		t.Errorf("expected audio data at offset, got %q (err = %v)", audio, err)
+	}
+}
+
+func TestTagString(t *testing.T) {
+	tag := NewTag()
+	tag.SetTitle("Title")
+	tag.SetArtists([]string{"A", "B"})
+	tag.SetTextFrame("TXXX:Key", "Value")
+	tag.SetComment(Comment{Language: "eng", Description: "desc", Text: "text"})
+	tag.Frames["APIC"] = []Frame{PictureFrame{
+		FrameHeader: FrameHeader{id: "APIC"},
+		MIMEType:    "image/png",
+		PictureType: 3,
+		Data:        make([]byte, 1234),
+	}}
+
+	expected := `ID3v2.4.0 (flags: none)
+APIC (Attached picture): Cover (front), image/png, <1234 bytes>
+COMM (Comments): eng/desc: text
+TIT2 (Title/songname/content description): Title
+TPE1 (Lead performer(s)/Soloist(s)): A / B
+TXXX (User defined text information frame): Key: Value
+`
+	if got := tag.String(); got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestTagDiff(t *testing.T) {
+	newTag := func() *Tag {
+		tag := NewTag()
+		tag.SetTitle("Title")
+		tag.SetArtist("Artist")
+		tag.SetComment(Comment{Language: "eng", Text: "text"})
+		return tag
+	}
+
+	a, b := newTag(), newTag()
+	if diff := a.Diff(b); len(diff) != 0 {
+		t.Errorf("expected no differences, got %v", diff)
+	}
+
+	b.SetArtist("Other artist")
+	b.SetAlbum("Album")
+	diff := a.Diff(b)
+	if len(diff) != 2 || diff[0] != "TALB" || diff[1] != "TPE1" {
+		t.Errorf("expected [TALB TPE1], got %v", diff)
+	}
+
+	if FramesEqual(a.Frames["TPE1"][0], b.Frames["TPE1"][0]) {
+		t.Error("frames with different text shouldn't be equal")
+	}
+	if !FramesEqual(a.Frames["TIT2"][0], b.Frames["TIT2"][0]) {
+		t.Error("identical frames should be equal")
+	}
+}
+
+func TestOversizedFrame(t *testing.T) {
+	tag := NewTag()
+	tag.SetTitle("Title")
+
+	buf := &bytes.Buffer{}
+	if err := NewEncoder(buf).WriteTag(tag); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	// Claim a frame size of 10 MB
+	copy(b[14:18], []byte{0x05, 0x00, 0x00, 0x00})
+
+	_, err := NewDecoder(bytes.NewReader(b)).Parse()
+	if _, ok := err.(InvalidFrameHeaderError); !ok {
+		t.Errorf("expected InvalidFrameHeaderError, got %v", err)
+	}
+}
+
+func TestEmptyFrames(t *testing.T) {
+	b := []byte("TIT2\x00\x00\x00\x00\x00\x00" +
+		"TPE1\x00\x00\x00\x07\x00\x00\x03Artist")
+	frame := parseFrame(t, b)
+	if frame.ID() != "TPE1" || frame.Value() != "Artist" {
+		t.Errorf("expected empty TIT2 to be skipped, got %s: %q", frame.ID(), frame.Value())
+	}
+
+	frame = parseFrame(t, []byte("TIT2\x00\x00\x00\x01\x00\x00\x03"))
+	if frame.ID() != "TIT2" || frame.Value() != "" {
+		t.Errorf("expected empty TIT2, got %s: %q", frame.ID(), frame.Value())
+	}
+}
+
+func TestShortFrames(t *testing.T) {
+	next := "TPE1\x00\x00\x00\x07\x00\x00\x03Artist"
+	for id, min := range minFrameSizes {
+		for size := 1; size < min; size++ {
+			b := []byte(string(id) + "\x00\x00\x00" + string(rune(size)) + "\x00\x00" +
+				strings.Repeat("\x03", size) + next)
+			d := NewDecoder(bytes.NewReader(b))
+			d.r = io.LimitReader(d.r, int64(len(b)))
+			d.h.Version = 0x0400
+			frame, err := d.ParseFrame()
+			if err != nil {
+				t.Fatalf("%s of size %d: %s", id, size, err)
+			}
+			if frame.ID() != "TPE1" || frame.Value() != "Artist" {
+				t.Errorf("%s of size %d: expected frame to be skipped, got %s", id, size, frame.ID())
+			}
+			want := DroppedFrameError{id, "frame too short"}
+			if w := d.Warnings(); len(w) != 1 || w[0] != want {
+				t.Errorf("%s of size %d: expected warning %v, got %v", id, size, want, w)
+			}
+		}
+	}
+}
+
+func TestUTF16Frames(t *testing.T) {
+	tests := []struct {
+		in  string
+		out Frame
+	}{
+		{
+			// Description "a", text "a\x00b"
+			"COMM\x00\x00\x00\x12\x00\x00\x01eng" +
+				"\xff\xfea\x00\x00\x00\xff\xfea\x00\x00\x00b\x00",
+			CommentFrame{FrameHeader: FrameHeader{id: "COMM"}, Language: "eng", Description: "a", Text: "a\x00b"},
+		},
+		{
+			// Description "A\u0100", lyrics "l", with an odd trailing
+			// byte
+			"USLT\x00\x00\x00\x0D\x00\x00\x02deu" +
+				"\x00A\x01\x00\x00\x00\x00l\x00",
+			UnsynchronisedLyricsFrame{FrameHeader: FrameHeader{id: "USLT"}, Language: "deu", Description: "A\u0100", Lyrics: "l"},
+		},
+		{
+			// Description "d", text "v"
+			"TXXX\x00\x00\x00\x0B\x00\x00\x01" +
+				"\xff\xfed\x00\x00\x00\xff\xfev\x00",
+			UserTextInformationFrame{FrameHeader: FrameHeader{id: "TXXX"}, Description: "d", Text: "v"},
+		},
+		{
+			// The description "AĀ" in UTF-16LE contains the bytes
+			// 00 00, but not aligned to a character boundary.
+			"WXXX\x00\x00\x00\x0D\x00\x00\x01" +
+				"\xff\xfeA\x00\x00\x01\x00\x00http",
+			UserDefinedURLLinkFrame{FrameHeader: FrameHeader{id: "WXXX"}, Description: "AĀ", URL: "http"},
+		},
+	}
+
+	for _, test := range tests {
+		frame := parseFrame(t, []byte(test.in))
+		if !reflect.DeepEqual(frame, test.out) {
+			t.Errorf("expected %#v, got %#v", test.out, frame)
+		}
+	}
+}
+
+func TestMissingTerminator(t *testing.T) {
+	tests := []struct {
+		in  string
+		out Frame
+	}{
+		{
+			"TXXX\x00\x00\x00\x05\x00\x00\x03desc",
+			UserTextInformationFrame{FrameHeader: FrameHeader{id: "TXXX"}, Description: "desc"},
+		},
+		{
+			"WXXX\x00\x00\x00\x05\x00\x00\x03desc",
+			UserDefinedURLLinkFrame{FrameHeader: FrameHeader{id: "WXXX"}, Description: "desc"},
+		},
+		{
+			"UFID\x00\x00\x00\x05\x00\x00owner",
+			UniqueFileIdentifierFrame{FrameHeader: FrameHeader{id: "UFID"}, Owner: "owner"},
+		},
+		{
+			"PRIV\x00\x00\x00\x05\x00\x00owner",
+			PrivateFrame{FrameHeader: FrameHeader{id: "PRIV"}, Owner: []byte("owner")},
+		},
+		{
+			"COMM\x00\x00\x00\x08\x00\x00\x03engdesc",
+			CommentFrame{FrameHeader: FrameHeader{id: "COMM"}, Language: "eng", Description: "desc"},
+		},
+	}
+
+	for _, test := range tests {
+		frame := parseFrame(t, []byte(test.in))
+		if !reflect.DeepEqual(frame, test.out) {
+			t.Errorf("expected %#v, got %#v", test.out, frame)
+		}
+	}
+}
+
+func TestManyDroppedFrames(t *testing.T) {
+	const n = 100000
+	frames := strings.Repeat("TIT2\x00\x00\x00\x00\x00\x00", n) +
+		"TPE1\x00\x00\x00\x07\x00\x00\x03Artist"
+	b := concat(generateHeader(len(frames), 0), []byte(frames))
+
+	d := NewDecoder(bytes.NewReader(b))
+	d.MaxFrames = 0
+	tag, err := d.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Artist() != "Artist" || tag.HasFrame("TIT2") {
+		t.Errorf("expected only the artist, got %v", tag)
+	}
+	if w := len(d.Warnings()); w != n {
+		t.Errorf("expected %d warnings, got %d", n, w)
+	}
+}
+
+func TestPictureFrameUTF16(t *testing.T) {
+	// The description "AĀ" in UTF-16LE contains the bytes 00 00,
+	// but not aligned to a character boundary.
+	body := "\x01image/png\x00\x03\xff\xfeA\x00\x00\x01\x00\x00\x89PNG"
+	b := []byte("APIC\x00\x00\x00" + string(byte(len(body))) + "\x00\x00" + body)
+
+	frame := parseFrame(t, b).(PictureFrame)
+	if frame.MIMEType != "image/png" {
+		t.Errorf("expected MIME type image/png, got %q", frame.MIMEType)
+	}
+	if frame.PictureType != 3 {
+		t.Errorf("expected picture type 3, got %d", frame.PictureType)
+	}
+	if frame.Description != "AĀ" {
+		t.Errorf("expected description %q, got %q", "AĀ", frame.Description)
+	}
+	if string(frame.Data) != "\x89PNG" {
+		t.Errorf("unexpected picture data %q", frame.Data)
+	}
+}
+
+// cancelReader calls cancel once n bytes have been read.
+type cancelReader struct {
+	r      io.Reader
+	n      int
+	cancel func()
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n -= n
+	if r.n <= 0 {
+		r.cancel()
+	}
+	return n, err
+}
+
+func TestParseContext(t *testing.T) {
+	tag := NewTag()
+	tag.SetTitle("Title")
+	tag.SetArtist("Artist")
+	title, artist := tag.Frames["TIT2"][0], tag.Frames["TPE1"][0]
+
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(title.Size() + artist.Size()); err != nil {
+		t.Fatal(err)
+	}
+	for _, frame := range []Frame{title, artist} {
+		if err := enc.WriteFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Cancel after the tag header and the TIT2 frame
+	r := &cancelReader{r: buf, n: 10 + title.Size(), cancel: cancel}
+	out, err := NewDecoder(r).ParseContext(ctx)
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if out.Title() != "Title" {
+		t.Errorf("expected title to have been parsed, got %q", out.Title())
+	}
+	if _, ok := out.Frames["TPE1"]; ok {
+		t.Error("didn't expect TPE1 to have been parsed")
+	}
+}
+
+func TestConversionConcurrency(t *testing.T) {
+	inputs := [][]byte{
+		[]byte("short"),
+		[]byte("a somewhat longer string \xe4\xf6\xfc"),
+		bytes.Repeat([]byte("\xdf"), 1000),
+		UTF16TestString,
+	}
+	convert := func(i int, in []byte) []byte {
+		if i == len(inputs)-1 {
+			return utf16ToUTF8(in)
+		}
+		return iso88591ToUTF8(in)
+	}
+	expected := make([]string, len(inputs))
+	for i, in := range inputs {
+		expected[i] = string(convert(i, in))
+	}
+
+	done := make(chan error)
+	for g := 0; g < 8; g++ {
+		go func() {
+			var outs [][]byte
+			for n := 0; n < 100; n++ {
+				for i, in := range inputs {
+					outs = append(outs, convert(i, in))
+				}
+			}
+			// Check the results only after all conversions, so that
+			// reused buffers would have overwritten them.
+			for n, out := range outs {
+				if exp := expected[n%len(inputs)]; string(out) != exp {
+					done <- fmt.Errorf("expected %q, got %q", exp, out)
+					return
+				}
+			}
+			done <- nil
+		}()
+	}
+	for g := 0; g < 8; g++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestWriteTagN(t *testing.T) {
+	tag := NewTag()
+	tag.SetTitle("Title")
+	tag.SetArtist("Artist")
+
+	buf := &bytes.Buffer{}
+	n, err := NewEncoder(buf).WriteTagN(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != buf.Len() {
+		t.Errorf("WriteTagN returned %d, but wrote %d bytes", n, buf.Len())
+	}
+}
+
+func TestSetTextFrameChecked(t *testing.T) {
+	tag := NewTag()
+	if err := tag.SetTextFrameChecked("TIT2", "Title"); err != nil {
+		t.Errorf("unexpected error for TIT2: %s", err)
+	}
+	if tag.Title() != "Title" {
+		t.Errorf("expected title to be set, got %q", tag.Title())
+	}
+
+	for _, name := range []FrameType{"TIT22", "WOAR", "TXXX", ""} {
+		if err := tag.SetTextFrameChecked(name, "value"); err == nil {
+			t.Errorf("expected error for %q", name)
+		}
+		if _, ok := tag.Frames[name]; ok {
+			t.Errorf("didn't expect frame %q to be set", name)
+		}
+	}
+
+	if err := tag.SetTextFrameChecked("TXXX:Key", "Value"); err != nil {
+		t.Errorf("unexpected error for user frame: %s", err)
+	}
+	if v := tag.GetTextFrame("TXXX:Key"); v != "Value" {
+		t.Errorf("expected user frame to be set, got %q", v)
+	}
+}
+
+func TestSetTextFrameSliceNormalized(t *testing.T) {
+	tag := NewTag()
+	tag.SetArtists([]string{"a", "", " b ", "a", "b"})
+	artists := tag.Artists()
+	if len(artists) != 2 || artists[0] != "a" || artists[1] != "b" {
+		t.Errorf("expected [a b], got %q", artists)
+	}
+
+	tag.SetArtists([]string{"", " "})
+	if _, ok := tag.Frames["TPE1"]; ok {
+		t.Error("expected TPE1 to be removed")
+	}
+
+	tag.SetTextFrame("TXXX:Other", "x")
+	tag.SetTextFrameSliceNormalized("TXXX:Key", []string{"a", "b"})
+	tag.SetTextFrameSliceNormalized("TXXX:Key", []string{" "})
+	if frames := tag.UserTextFrames(); len(frames) != 1 || frames[0].Description != "Other" {
+		t.Errorf("expected only TXXX:Key to be removed, got %v", frames)
+	}
+}
+
+func TestPadding(t *testing.T) {
+	for _, padding := range []int{0, 5, 10000} {
+		tag := NewTag()
+		tag.SetTitle("Title")
+
+		buf := &bytes.Buffer{}
+		enc := NewEncoder(buf)
+		enc.Padding = padding
+		if err := enc.WriteTag(tag); err != nil {
+			t.Fatal(err)
+		}
+
+		d := NewDecoder(buf)
+		out, err := d.Parse()
+		if err != nil {
+			t.Errorf("couldn't parse tag with %d bytes of padding: %s", padding, err)
+			continue
+		}
+		if out.Title() != "Title" {
+			t.Errorf("expected title %q, got %q", "Title", out.Title())
+		}
+		if d.Padding() != padding {
+			t.Errorf("expected %d bytes of padding, got %d", padding, d.Padding())
+		}
+		if buf.Len() != 0 {
+			t.Errorf("expected entire tag to be consumed, %d bytes left", buf.Len())
+		}
+	}
+}
+
+func TestTagSize(t *testing.T) {
+	tag := NewTag()
+	// Large enough to need all four bytes of the synchsafe size
+	tag.Frames["PRIV"] = []Frame{PrivateFrame{
+		FrameHeader: FrameHeader{id: "PRIV"},
+		Owner:       []byte("owner"),
+		Data:        make([]byte, 3<<20),
+	}}
+
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	enc.Padding = 0
+	n, err := enc.WriteTagN(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDecoder(buf)
+	h, err := d.ParseHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Size+10 != n {
+		t.Errorf("header declares %d bytes, but %d bytes were written", h.Size+10, n)
+	}
+	for {
+		_, err := d.ParseFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d.remaining() != 0 || buf.Len() != 0 {
+		t.Errorf("expected to be at the end of the tag, %d bytes remaining", d.remaining())
+	}
+
+	enc.Padding = -1
+	if err := enc.WriteTag(tag); err != ErrNegativePadding {
+		t.Errorf("expected ErrNegativePadding, got %v", err)
+	}
+}
+
+func TestFindTag(t *testing.T) {
+	tag := NewTag()
+	tag.SetTitle("Title")
+
+	buf := &bytes.Buffer{}
+	// Junk, including a false positive
+	buf.WriteString("junkID3\x04\xff\x00junk!!")
+	if buf.Len() != 16 {
+		t.Fatalf("expected 16 bytes of junk, got %d", buf.Len())
+	}
+	if err := NewEncoder(buf).WriteTag(tag); err != nil {
+		t.Fatal(err)
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+	offset, h, err := FindTag(r, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 16 {
+		t.Errorf("expected tag at offset 16, got %d", offset)
+	}
+	if h.Version != 0x0400 {
+		t.Errorf("expected version 2.4, got %s", h.Version)
+	}
+
+	out, err := NewDecoder(io.NewSectionReader(r, offset, int64(buf.Len()))).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Title() != "Title" {
+		t.Errorf("expected title %q, got %q", "Title", out.Title())
+	}
+
+	if _, _, err := FindTag(r, 10); err != ErrNoTag {
+		t.Errorf("expected ErrNoTag when scanning too few bytes, got %v", err)
+	}
+}
+
+func TestRestrictions(t *testing.T) {
+	tag := NewTag()
+	tag.SetTitle(strings.Repeat("ä", 40))
+	tag.SetArtists([]string{"short", strings.Repeat("b", 31)})
+
+	// At most 30 characters per text field
+	restrictions := Restrictions(0x18)
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	enc.Restrictions = &restrictions
+	if err := enc.WriteTag(tag); err != nil {
+		t.Fatal(err)
+	}
+	if tag.Title() != strings.Repeat("ä", 40) {
+		t.Error("encoding shouldn't modify the tag")
+	}
+
+	d := NewDecoder(buf)
+	out, err := d.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext := d.ExtendedHeader(); !ext.HasRestrictions || ext.Restrictions != restrictions {
+		t.Errorf("expected restrictions %#x in extended header, got %+v", restrictions, ext)
+	}
+	if out.Title() != strings.Repeat("ä", 30) {
+		t.Errorf("expected title to be truncated to 30 characters, got %q", out.Title())
+	}
+	artists := out.Artists()
+	if len(artists) != 2 || artists[0] != "short" || artists[1] != strings.Repeat("b", 30) {
+		t.Errorf("expected artists to be truncated, got %q", artists)
+	}
+
+	// Text restricted to ISO-8859-1 and UTF-8, applied before
+	// compression
+	restrictions = Restrictions(0x38)
+	tag = NewTag()
+	tag.Frames["TIT2"] = []Frame{TextInformationFrame{
+		FrameHeader: FrameHeader{id: "TIT2"},
+		Text:        strings.Repeat("ö", 100),
+		Encoding:    utf16bom,
+	}}
+	tag.SetComment(Comment{Language: "eng", Text: strings.Repeat("c", 100)})
+	tag.Frames["USLT"] = []Frame{NewLyricsFrame("eng", strings.Repeat("d", 100), "lyrics")}
+	for _, compress := range []bool{false, true} {
+		buf.Reset()
+		enc.PreserveEncoding = true
+		if compress {
+			enc.Compress = func(FrameType) bool { return true }
+		}
+		if err := enc.WriteTag(tag); err != nil {
+			t.Fatal(err)
+		}
+		out, err := NewDecoder(buf).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		title := out.Frames["TIT2"][0].(TextInformationFrame)
+		if title.Text != strings.Repeat("ö", 30) || title.Encoding != utf8 {
+			t.Errorf("compress = %t: expected truncated UTF-8 title, got %q in %s", compress, title.Text, title.Encoding)
+		}
+		if c, _ := out.Comment("eng", ""); c.Text != strings.Repeat("c", 30) {
+			t.Errorf("compress = %t: expected truncated comment, got %q", compress, c.Text)
+		}
+		if l := out.Frames["USLT"][0].(UnsynchronisedLyricsFrame); l.Description != strings.Repeat("d", 30) {
+			t.Errorf("compress = %t: expected truncated lyrics description, got %q", compress, l.Description)
+		}
+	}
+	enc.PreserveEncoding = false
+	enc.Compress = nil
+
+	// At most 4 KB
+	restrictions = Restrictions(0xC0)
+	enc.Padding = 5000
+	if err := enc.WriteTag(tag); err == nil {
+		t.Error("expected tag with 5000 bytes of padding to exceed 4 KB restriction")
+	} else if _, ok := err.(RestrictionError); !ok {
+		t.Errorf("expected RestrictionError, got %v", err)
+	}
+}
+
+func TestCRC(t *testing.T) {
+	tag := NewTag()
+	tag.SetTitle("Title")
+
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	enc.WriteCRC = true
+	if err := enc.WriteTag(tag); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+
+	d := NewDecoder(bytes.NewReader(b))
+	if _, err := d.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if !d.ExtendedHeader().HasCRC {
+		t.Error("expected extended header to contain a CRC")
+	}
+
+	// Corrupt the last byte of the padding
+	b[len(b)-1] = 1
+	out, err := NewDecoder(bytes.NewReader(b)).Parse()
+	if _, ok := err.(CRCMismatchError); !ok {
+		t.Errorf("expected CRCMismatchError, got %v", err)
+	}
+	if out.Title() != "Title" {
+		t.Errorf("expected tag to be parsed despite CRC mismatch, got title %q", out.Title())
+	}
+}
+
+func TestMerge(t *testing.T) {
+	newTags := func() (*Tag, *Tag) {
+		v2 := NewTag()
+		v2.SetTitle("Full title")
+		v2.SetAlbum("Album")
+		v2.SetTextFrame("TXXX:Key", "v2")
+		v2.SetComment(Comment{Language: "eng", Text: "v2 comment"})
+
+		v1 := NewTag()
+		v1.SetTitle("Title")
+		v1.SetArtist("Artist")
+		v1.SetTextFrame("TXXX:Key", "v1")
+		v1.SetTextFrame("TXXX:Other", "v1")
+		v1.SetComment(Comment{Language: "eng", Text: "v2 comment"})
+		v1.SetComment(Comment{Language: "deu", Text: "v1 comment"})
+		return v2, v1
+	}
+
+	v2, v1 := newTags()
+	v2.Merge(v1, false)
+	if v2.Title() != "Full title" || v2.Artist() != "Artist" || v2.Album() != "Album" {
+		t.Errorf("unexpected title %q, artist %q, album %q", v2.Title(), v2.Artist(), v2.Album())
+	}
+	if v := v2.GetTextFrame("TXXX:Key"); v != "v2" {
+		t.Errorf("expected existing user frame to be kept, got %q", v)
+	}
+	if v := v2.GetTextFrame("TXXX:Other"); v != "v1" {
+		t.Errorf("expected new user frame to be added, got %q", v)
+	}
+	if n := len(v2.Frames["COMM"]); n != 2 {
+		t.Errorf("expected 2 comments, got %d", n)
+	}
+
+	v2, v1 = newTags()
+	v2.Merge(v1, true)
+	if v2.Title() != "Title" || v2.Artist() != "Artist" || v2.Album() != "Album" {
+		t.Errorf("unexpected title %q, artist %q, album %q", v2.Title(), v2.Artist(), v2.Album())
+	}
+	if v := v2.GetTextFrame("TXXX:Key"); v != "v1" {
+		t.Errorf("expected user frame to be overwritten, got %q", v)
+	}
+	if n := len(v2.Frames["TXXX"]); n != 2 {
+		t.Errorf("expected 2 user text frames, got %d", n)
+	}
+	if n := len(v2.Frames["COMM"]); n != 2 {
+		t.Errorf("expected 2 comments, got %d", n)
+	}
+}
+
+func TestParseFrameRaw(t *testing.T) {
+	body := "\x09Title"
+	b := []byte("TIT2\x00\x00\x00\x06\x00\x00" + body)
+	d := NewDecoder(bytes.NewReader(b))
+	d.r = io.LimitReader(d.r, int64(len(b)))
+	d.h.Version = 0x0400
+
+	id, flags, raw, err := d.ParseFrameRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "TIT2" || flags != 0 {
+		t.Errorf("unexpected ID %q and flags %s", id, flags)
+	}
+	if string(raw) != body {
+		t.Errorf("expected body %q, got %q", body, raw)
+	}
+	if _, _, _, err := d.ParseFrameRaw(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestPreserveEncoding(t *testing.T) {
+	b := []byte("TIT2\x00\x00\x00\x06\x00\x00\x00T\xeftle")
+	frame := parseFrame(t, b).(TextInformationFrame)
+	if frame.Encoding != iso88591 || frame.Text != "Tïtle" {
+		t.Fatalf("unexpected encoding %s and text %q", frame.Encoding, frame.Text)
+	}
+
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	enc.PreserveEncoding = true
+	if err := enc.WriteFrame(frame); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Errorf("expected frame to be written as ISO-8859-1, got %q", buf.Bytes())
+	}
+
+	buf.Reset()
+	enc.PreserveEncoding = false
+	if err := enc.WriteFrame(frame); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.Bytes(); out[10] != byte(utf8) || string(out[11:]) != "Tïtle" {
+		t.Errorf("expected frame to be written as UTF-8, got %q", out)
+	}
+
+	tag := NewTag()
+	tag.SetTitle("Tïtle")
+	if !FramesEqual(frame, tag.Frames["TIT2"][0]) {
+		t.Error("frames with different encodings should be equal")
+	}
+
+	// Text that can't be represented in ISO-8859-1
+	frame.Text = "Title ♫"
+	buf.Reset()
+	enc.PreserveEncoding = true
+	if err := enc.WriteFrame(frame); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.Bytes(); out[10] != byte(utf8) || string(out[11:]) != "Title ♫" {
+		t.Errorf("expected frame to be written as UTF-8, got %q", out)
+	}
+}
+
+func TestScan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "id3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tag := NewTag()
+	tag.SetTitle("Title")
+	buf := &bytes.Buffer{}
+	if err := NewEncoder(buf).WriteTag(tag); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		"valid.mp3":   buf.Bytes(),
+		"invalid.mp3": []byte("not a tag"),
+		"empty.mp3":   nil,
+		"broken.mp3":  []byte("ID3\x09\x00\x00\x00\x00\x00\x00"),
+	}
+	var paths []string
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	paths = append(paths, filepath.Join(dir, "missing.mp3"))
+
+	results := map[string]Result{}
+	for res := range Scan(paths, 2) {
+		results[filepath.Base(res.Path)] = res
+	}
+	if len(results) != len(paths) {
+		t.Fatalf("expected %d results, got %d", len(paths), len(results))
+	}
+
+	if res := results["valid.mp3"]; res.Err != nil || res.Tag.Title() != "Title" {
+		t.Errorf("unexpected result for valid file: %+v", res)
+	}
+	if res := results["invalid.mp3"]; res.Err != ErrNoTag {
+		t.Errorf("expected ErrNoTag, got %v", res.Err)
+	}
+	if res := results["empty.mp3"]; res.Err != ErrNoTag {
+		t.Errorf("expected ErrNoTag for empty file, got %v", res.Err)
+	}
+	if res := results["broken.mp3"]; res.Err == nil {
+		t.Error("expected error for unsupported version")
+	}
+	if res := results["missing.mp3"]; !os.IsNotExist(res.Err) {
+		t.Errorf("expected file not to exist, got %v", res.Err)
+	}
+}
+
+func TestPictureFrameDecode(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 3))
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	frame := PictureFrame{MIMEType: "image/png", Data: buf.Bytes()}
+	if ext := frame.Extension(); ext != ".png" {
+		t.Errorf("expected extension .png, got %q", ext)
+	}
+	out, format, err := frame.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "png" || out.Bounds() != img.Bounds() {
+		t.Errorf("unexpected format %q and bounds %v", format, out.Bounds())
+	}
+
+	frame.Data = frame.Data[:10]
+	if _, _, err := frame.Decode(); err == nil {
+		t.Error("expected error for corrupt image")
+	}
+}
+
+func TestValidatePictures(t *testing.T) {
+	tag := NewTag()
+	tag.SetTitle("Title")
+	if err := tag.Validate(); err != nil {
+		t.Errorf("unexpected error for valid tag: %s", err)
+	}
+
+	picture := func(typ PictureType, desc string) Frame {
+		return PictureFrame{
+			FrameHeader: FrameHeader{id: "APIC"},
+			MIMEType:    "image/png",
+			PictureType: typ,
+			Description: desc,
+		}
+	}
+	tag.Frames["APIC"] = []Frame{
+		picture(3, "front 1"),
+		picture(1, "icon 1"),
+		picture(3, "front 2"),
+		picture(1, "icon 2"),
+	}
+
+	err, ok := tag.Validate().(ValidationError)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %v", tag.Validate())
+	}
+	if len(err.Problems) != 2 {
+		t.Fatalf("expected 2 problems, got %v", err.Problems)
+	}
+	var warnings int
+	for _, p := range err.Problems {
+		if p.Warning {
+			warnings++
+		}
+	}
+	if warnings != 1 {
+		t.Errorf("expected one warning, got %d", warnings)
+	}
+
+	tag.Sanitize()
+	var descs []string
+	for _, frame := range tag.Frames["APIC"] {
+		descs = append(descs, frame.(PictureFrame).Description)
+	}
+	if strings.Join(descs, ", ") != "front 1, icon 1, front 2" {
+		t.Errorf("unexpected pictures after sanitizing: %q", descs)
+	}
+	if tag.Title() != "Title" {
+		t.Error("expected valid frames to be kept")
+	}
+}
+
+func TestStampSoftware(t *testing.T) {
+	enc := NewEncoder(ioutil.Discard)
+	enc.StampSoftware = "go-id3 1.0"
+
+	tag := NewTag()
+	if err := enc.WriteTag(tag); err != nil {
+		t.Fatal(err)
+	}
+	if s := tag.EncodingSoftware(); s != "go-id3 1.0" {
+		t.Errorf("expected software to be stamped, got %q", s)
+	}
+
+	tag.SetEncodingSoftware("LAME 3.100")
+	if err := enc.WriteTag(tag); err != nil {
+		t.Fatal(err)
+	}
+	if s := tag.EncodingSoftware(); s != "LAME 3.100" {
+		t.Errorf("expected existing software to be kept, got %q", s)
+	}
+
+	enc.OverwriteSoftware = true
+	if err := enc.WriteTag(tag); err != nil {
+		t.Fatal(err)
+	}
+	if s := tag.EncodingSoftware(); s != "go-id3 1.0" {
+		t.Errorf("expected software to be overwritten, got %q", s)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	b := []byte("TIT2\x00\x00\x00\x06\x00\x00\x03Title" +
+		"COMM\x00\x00\x00\x09\x00\x00\x09eng\x00text")
+	d := NewDecoder(bytes.NewReader(b))
+	d.r = io.LimitReader(d.r, int64(len(b)))
+	d.h.Version = 0x0400
+
+	var frames []Frame
+	for {
+		frame, err := d.ParseFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, frame)
+	}
+
+	if len(frames) != 1 || frames[0].ID() != "TIT2" {
+		t.Errorf("expected only TIT2 to be parsed, got %v", frames)
+	}
+	warnings := d.Warnings()
+	if len(warnings) != 1 {
+		t.Fatalf("expected exactly one warning, got %v", warnings)
+	}
+	if err, ok := warnings[0].(DroppedFrameError); !ok || err.ID != "COMM" {
+		t.Errorf("expected COMM to be dropped, got %v", warnings[0])
+	}
+}
+
+func TestITunesNormalization(t *testing.T) {
+	const norm = " 00000325 000002E4 00002B3A 000023E8 0001A5A4 0001A5A4 00007D6C 00007DCC 0002A6F5 0002A6F5"
+
+	tag := NewTag()
+	tag.SetITunesNormalization(norm)
+	tag.SetComment(Comment{Language: "eng", Text: "comment"})
+
+	roundTrip := func(tag *Tag) *Tag {
+		buf := &bytes.Buffer{}
+		if err := NewEncoder(buf).WriteTag(tag); err != nil {
+			t.Fatal(err)
+		}
+		out, err := NewDecoder(buf).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	tag = roundTrip(tag)
+	tag.SetComment(Comment{Language: "eng", Text: "edited comment"})
+	tag = roundTrip(tag)
+
+	if v, ok := tag.ITunesNormalization(); !ok || v != norm {
+		t.Errorf("expected iTunNORM to survive unchanged, got %q", v)
+	}
+	if c, _ := tag.Comment("eng", ""); c.Text != "edited comment" {
+		t.Errorf("expected comment to be edited, got %q", c.Text)
+	}
+	if n := len(tag.Comments()); n != 2 {
+		t.Errorf("expected 2 comments, got %d", n)
+	}
+
+	tag.SetComments([]Comment{{Language: "eng", Text: "new comment"}})
+	if v, ok := tag.ITunesNormalization(); !ok || v != norm {
+		t.Errorf("expected SetComments to keep iTunNORM, got %q", v)
+	}
+}
+
+func TestDecoderLimits(t *testing.T) {
+	tag := NewTag()
+	tag.SetTitle("Title")
+	tag.SetArtist("Artist")
+	tag.SetAlbum("Album")
+	buf := &bytes.Buffer{}
+	if err := NewEncoder(buf).WriteTag(tag); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+
+	d := NewDecoder(bytes.NewReader(b))
+	d.MaxFrames = 2
+	out, err := d.Parse()
+	if err, ok := err.(LimitExceededError); !ok || err.Max != 2 {
+		t.Errorf("expected LimitExceededError for frames, got %v", err)
+	}
+	if n := len(out.Frames); n != 2 {
+		t.Errorf("expected 2 frames to have been parsed, got %d", n)
+	}
+
+	// Dropped frames count towards the limit, too.
+	frames := strings.Repeat("TIT2\x00\x00\x00\x00\x00\x00", 10)
+	d = NewDecoder(bytes.NewReader(concat(generateHeader(len(frames), 0), []byte(frames))))
+	d.MaxFrames = 5
+	if _, err := d.Parse(); err != (LimitExceededError{"number of frames", 6, 5}) {
+		t.Errorf("expected LimitExceededError for dropped frames, got %v", err)
+	}
+	if n := len(d.Warnings()); n != 5 {
+		t.Errorf("expected 5 dropped frames, got %d", n)
+	}
+
+	d = NewDecoder(bytes.NewReader(b))
+	d.MaxTagSize = 100
+	if _, err := d.Parse(); err == nil {
+		t.Error("expected LimitExceededError for tag size")
+	} else if _, ok := err.(LimitExceededError); !ok {
+		t.Errorf("expected LimitExceededError for tag size, got %v", err)
+	}
+
+	d = NewDecoder(bytes.NewReader(b))
+	d.MaxFrames = 0
+	d.MaxTagSize = 0
+	if _, err := d.Parse(); err != nil {
+		t.Errorf("unexpected error without limits: %s", err)
+	}
+}
+
+func TestFrameSizes(t *testing.T) {
+	h := func(id FrameType) FrameHeader { return FrameHeader{id: id} }
+	frames := []Frame{
+		TextInformationFrame{FrameHeader: h("TIT2"), Text: "Tïtle\x00♫", Encoding: utf8},
+		TextInformationFrame{FrameHeader: h("TIT2"), Text: "Tïtle\x00Other", Encoding: iso88591},
+		TextInformationFrame{FrameHeader: h("TSIZ"), Text: "123"},
+		UserTextInformationFrame{FrameHeader: h("TXXX"), Description: "Désc", Text: "Tëxt"},
+		UniqueFileIdentifierFrame{FrameHeader: h("UFID"), Owner: "ownér", Identifier: []byte("id")},
+		URLLinkFrame{FrameHeader: h("WOAR"), URL: "http://exämple.com"},
+		UserDefinedURLLinkFrame{FrameHeader: h("WXXX"), Description: "Désc", URL: "http://exämple.com"},
+		CommentFrame{FrameHeader: h("COMM"), Language: "en", Description: "Désc", Text: "Tëxt"},
+		PrivateFrame{FrameHeader: h("PRIV"), Owner: []byte("owner"), Data: []byte("data")},
+		PictureFrame{FrameHeader: h("APIC"), MIMEType: "image/pñg", PictureType: 3, Description: "Désc", Data: []byte("data")},
+		MusicCDIdentifierFrame{FrameHeader: h("MCDI"), TOC: []byte("toc")},
+		UnsynchronisedLyricsFrame{FrameHeader: h("USLT"), Language: "english", Description: "Désc", Lyrics: "Lÿrics"},
+		RelativeVolumeAdjustmentFrame{FrameHeader: h("RVA2"), Identification: "träck", Adjustments: []VolumeAdjustment{{MasterVolume, -3.5, 0.9}, {FrontLeft, 1, 0}}},
+		EventTimingFrame{FrameHeader: h("ETCO"), TimestampFormat: Milliseconds, Events: []TimingEvent{{1, 1000}}},
+		SynchronisedLyricsFrame{FrameHeader: h("SYLT"), Language: "eng", TimestampFormat: Milliseconds, ContentType: 1, Description: "Désc", Lines: []SyncedText{{"Lïne", 100}}},
+		AudioSeekPointIndexFrame{FrameHeader: h("ASPI"), DataStart: 1, DataLength: 2, BitsPerPoint: 16, Points: []uint16{1, 2}},
+		OwnershipFrame{FrameHeader: h("OWNE"), PricePaid: "EUR9.99", DatePurchased: "2020", Seller: "Sëller"},
+		CommercialFrame{FrameHeader: h("COMR"), Price: "EUR9.99", ValidUntil: "20200101", ContactURL: "http://exämple.com", ReceivedAs: 1, Seller: "Sëller", Description: "Désc", LogoMIMEType: "image/pñg", Logo: []byte("logo")},
+		PositionSyncFrame{FrameHeader: h("POSS"), TimestampFormat: MPEGFrames, Position: 1},
+		RecommendedBufferFrame{FrameHeader: h("RBUF"), BufferSize: 1, EmbeddedInfo: true, NextFlagOffset: 2},
+		MPEGLookupTableFrame{FrameHeader: h("MLLT"), FramesBetweenReference: 1, BytesBetweenReference: 2, MillisecondsBetweenReference: 3, BitsForBytesDeviation: 4, BitsForMillisecondsDeviation: 4, References: []MPEGLookupReference{{1, 2}, {3, 4}, {5, 6}}},
+		GroupRegistrationFrame{FrameHeader: h("GRID"), Owner: "ownér", GroupSymbol: 0x80, Data: []byte("data")},
+		EncryptionRegistrationFrame{FrameHeader: h("ENCR"), Owner: "ownér", MethodSymbol: 0x80, Data: []byte("data")},
+		LinkFrame{FrameHeader: h("LINK"), FrameIdentifier: [4]byte{'C', 'O', 'M', 'M'}, URL: "http://exämple.com", IDAndData: []byte("eng")},
+		ReverbFrame{FrameHeader: h("RVRB"), Left: 1, Right: 2},
+		AudioEncryptionFrame{FrameHeader: h("AENC"), Owner: "ownér", PreviewStart: 1, PreviewLength: 2, EncryptionInfo: []byte("info")},
+		SignatureFrame{FrameHeader: h("SIGN"), GroupSymbol: 0x80, Signature: []byte("sig")},
+		TermsOfUseFrame{FrameHeader: h("USER"), Language: "eng", Text: "Tërms"},
+		UnsupportedFrame{FrameHeader: h("XXXX"), Data: []byte("data")},
+	}
+
+	for _, f := range frames {
+		if err := checkFrameSize(f); err != nil {
+			t.Errorf("%T: %s", f, err)
+		}
+	}
+
+	tag := NewTag()
+	tag.Frames["XXXX"] = []Frame{badSizeFrame{UnsupportedFrame{FrameHeader: h("XXXX"), Data: []byte("data")}}}
+	enc := NewEncoder(ioutil.Discard)
+	enc.VerifySizes = true
+	if _, ok := enc.WriteTag(tag).(FrameSizeError); !ok {
+		t.Error("expected FrameSizeError")
+	}
+}
+
+type badSizeFrame struct {
+	UnsupportedFrame
+}
+
+func (f badSizeFrame) Size() int { return f.UnsupportedFrame.Size() + 1 }
+
+func TestUTF16MultipleValues(t *testing.T) {
+	for _, enc := range []Encoding{utf16bom, utf16be} {
+		frame := TextInformationFrame{
+			FrameHeader: FrameHeader{id: "TPE1"},
+			Text:        "Ärtist\x00Other ♫",
+			Encoding:    enc,
+		}
+
+		buf := &bytes.Buffer{}
+		e := NewEncoder(buf)
+		e.PreserveEncoding = true
+		if err := e.WriteFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() != frame.Size() {
+			t.Errorf("%s: wrote %d bytes, but size is %d", enc, buf.Len(), frame.Size())
+		}
+
+		out := parseFrame(t, buf.Bytes()).(TextInformationFrame)
+		if out.Encoding != enc {
+			t.Errorf("expected encoding %s, got %s", enc, out.Encoding)
+		}
+		tag := NewTag()
+		tag.Frames["TPE1"] = []Frame{out}
+		artists := tag.Artists()
+		if len(artists) != 2 || artists[0] != "Ärtist" || artists[1] != "Other ♫" {
+			t.Errorf("%s: expected two artists, got %q", enc, artists)
+		}
+	}
+}
+
+func TestDecoderReset(t *testing.T) {
+	encode := func(tag *Tag) []byte {
+		buf := &bytes.Buffer{}
+		if err := NewEncoder(buf).WriteTag(tag); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	tag1 := NewTag()
+	tag1.SetTitle("First title")
+	tag1.SetArtist("Artist")
+	tag2 := NewTag()
+	tag2.SetTitle("Second")
+
+	d := NewDecoder(bytes.NewReader(encode(tag1)))
+	out, err := d.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Title() != "First title" {
+		t.Errorf("expected title %q, got %q", "First title", out.Title())
+	}
+
+	d.Reset(bytes.NewReader(encode(tag2)))
+	out, err = d.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Title() != "Second" || out.HasFrame("TPE1") {
+		t.Errorf("unexpected title %q and artist %q", out.Title(), out.Artist())
+	}
+	if d.Padding() != 1024 {
+		t.Errorf("expected 1024 bytes of padding, got %d", d.Padding())
+	}
+}
+
+func TestLookupTextFrame(t *testing.T) {
+	tag := NewTag()
+	if _, ok := tag.LookupTextFrame("TALB"); ok {
+		t.Error("expected absent TALB")
+	}
+	if tag.HasFrame("TALB") {
+		t.Error("expected HasFrame to report absent TALB")
+	}
+
+	tag.SetAlbum("")
+	if v, ok := tag.LookupTextFrame("TALB"); !ok || v != "" {
+		t.Errorf("expected present, empty TALB, got %q, %t", v, ok)
+	}
+
+	if _, ok := tag.LookupTextFrame("TXXX:Key"); ok {
+		t.Error("expected absent user frame")
+	}
+	tag.SetTextFrame("TXXX:Key", "")
+	if v, ok := tag.LookupTextFrame("TXXX:Key"); !ok || v != "" {
+		t.Errorf("expected present, empty user frame, got %q, %t", v, ok)
+	}
+	if !tag.HasFrame("TXXX:Key") || tag.HasFrame("TXXX:Other") {
+		t.Error("HasFrame doesn't handle user frames")
+	}
+}
+
+func TestDataLengthIndicator(t *testing.T) {
+	frame := parseFrame(t, []byte("TIT2\x00\x00\x00\x0A\x00\x01\x00\x00\x00\x06\x03Title"))
+	tf, ok := frame.(TextInformationFrame)
+	if !ok {
+		t.Fatalf("expected TextInformationFrame, got %T", frame)
+	}
+	if tf.Text != "Title" {
+		t.Errorf("expected %q, got %q", "Title", tf.Text)
+	}
+	if tf.Flags() != 0 {
+		t.Errorf("expected data length indicator flag to be cleared, got %04x", tf.Flags())
+	}
+
+	// A length that doesn't match the frame's data drops the frame.
+	b := []byte("TIT2\x00\x00\x00\x0A\x00\x01\x00\x00\x00\x09\x03TitleTALB\x00\x00\x00\x04\x00\x00\x03Foo")
+	d := NewDecoder(bytes.NewReader(b))
+	d.r = io.LimitReader(d.r, int64(len(b)))
+	d.h.Version = 0x0400
+	frame, err := d.ParseFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.ID() != "TALB" {
+		t.Errorf("expected TIT2 to be dropped, got %s", frame.ID())
+	}
+	if len(d.Warnings()) != 1 {
+		t.Errorf("expected one warning, got %v", d.Warnings())
+	}
+}
+
+// v1Tag returns an ID3v1.1 tag with the given fields.
+func v1Tag(title, artist string, genre byte) []byte {
+	field := func(s string, n int) []byte {
+		b := make([]byte, n)
+		copy(b, s)
+		return b
+	}
+	return concat(
+		[]byte("TAG"),
+		field(title, 30),
+		field(artist, 30),
+		field("", 30),
+		field("2000", 4),
+		field("", 30),
+		[]byte{genre},
+	)
+}
+
+func TestStripTags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "id3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tag := NewTag()
+	tag.SetTitle("Title")
+	buf := &bytes.Buffer{}
+	if err := NewEncoder(buf).WriteTag(tag); err != nil {
+		t.Fatal(err)
+	}
+	audio := bytes.Repeat([]byte{0xFF, 0xFB, 0x90, 0x00}, 100)
+	buf.Write(audio)
+	buf.Write(v1Tag("Title", "Artist", 17))
+
+	path := filepath.Join(dir, "song.mp3")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := StripTags(path); err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, audio) {
+		t.Errorf("expected only audio data to remain, got %d bytes", len(out))
+	}
+
+	// Files without tags are left alone.
+	if err := StripTags(path); err != nil {
+		t.Fatal(err)
+	}
+	out, err = ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, audio) {
+		t.Error("StripTags modified a file without tags")
+	}
+
+	// Files whose tag claims to be larger than the file are left
+	// alone, too.
+	broken := concat([]byte("ID3\x04\x00\x00\x7f\x7f\x7f\x7f"), make([]byte, 5000))
+	if err := ioutil.WriteFile(path, broken, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := StripTags(path).(TruncatedTagError); !ok {
+		t.Error("expected TruncatedTagError for oversized tag")
+	}
+	out, err = ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, broken) {
+		t.Errorf("StripTags modified a file with an oversized tag, got %d bytes", len(out))
+	}
+}
+
+func TestParseFileMergeV1(t *testing.T) {
+	dir, err := ioutil.TempDir("", "id3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tag := NewTag()
+	tag.SetTitle("Title (v2)")
+	buf := &bytes.Buffer{}
+	if err := NewEncoder(buf).WriteTag(tag); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(make([]byte, 64))
+	buf.Write(v1Tag("Title (v1)", "Artist", 17))
+	path := filepath.Join(dir, "song.mp3")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tag, err = ParseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.HasFrame("TCON") {
+		t.Error("expected ID3v1 tag to be ignored without MergeV1")
+	}
+
+	d := NewDecoder(nil)
+	d.MergeV1 = true
+	tag, err = d.ParseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tag.GetTextFrame("TCON"); got != "Rock" {
+		t.Errorf("expected genre from ID3v1 tag, got %q", got)
+	}
+	if got := tag.Title(); got != "Title (v2)" {
+		t.Errorf("expected ID3v2 title to take precedence, got %q", got)
+	}
+	if got := tag.Artist(); got != "Artist" {
+		t.Errorf("expected artist from ID3v1 tag, got %q", got)
+	}
+
+	// Without an ID3v2 tag, the ID3v1 tag is used on its own.
+	if err := StripTags(path); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(v1Tag("Title (v1)", "", 255))
+	f.Close()
+	tag, err = ParseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tag.Title(); got != "Title (v1)" || tag.HasFrame("TCON") {
+		t.Errorf("unexpected tag %v", tag)
+	}
+}
+
+func TestPictureTypeByName(t *testing.T) {
+	for i, name := range PictureTypes {
+		typ, ok := PictureTypeByName(name)
+		if !ok || typ != PictureType(i) {
+			t.Errorf("%q: expected type %d, got %d, %t", name, i, typ, ok)
+		}
+		if typ.String() != name {
+			t.Errorf("expected %q, got %q", name, typ.String())
+		}
+	}
+
+	if typ, ok := PictureTypeByName("cover (FRONT)"); !ok || typ != 3 {
+		t.Errorf("expected case-insensitive match, got %d, %t", typ, ok)
+	}
+	if _, ok := PictureTypeByName("A dull grey fish"); ok {
+		t.Error("expected unknown name not to match")
+	}
+}
+
+func TestUTF8InV23(t *testing.T) {
+	b := []byte("ID3\x03\x00\x00\x00\x00\x00\x10" +
+		"TIT2\x00\x00\x00\x06\x00\x00\x03Title")
+	d := NewDecoder(bytes.NewReader(b))
+	tag, err := d.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Title() != "Title" {
+		t.Errorf("expected title %q, got %q", "Title", tag.Title())
+	}
+	warnings := d.Warnings()
+	if len(warnings) != 1 {
+		t.Fatalf("expected exactly one warning, got %v", warnings)
+	}
+	if err, ok := warnings[0].(EncodingVersionError); !ok || err.ID != "TIT2" {
+		t.Errorf("expected encoding warning for TIT2, got %v", warnings[0])
+	}
+}
+
+func TestUTF16ToUTF8Malformed(t *testing.T) {
+	tests := []struct {
+		in  []byte
+		out string
+	}{
+		{nil, ""},
+		{[]byte{}, ""},
+		{[]byte{0xFF}, ""},
+		{[]byte{0xFF, 0xFE}, ""},
+		{[]byte{0xFF, 0xFE, 'a'}, ""},
+		{[]byte{0xFF, 0xFE, 'a', 0, 'b'}, "a"},
+		{[]byte{0, 'a', 0}, "a"},
+	}
+	for _, test := range tests {
+		if out := utf16ToUTF8(test.in); string(out) != test.out {
+			t.Errorf("%q: expected %q, got %q", test.in, test.out, out)
+		}
+	}
+
+	// An empty UTF-16 text frame
+	frame := parseFrame(t, []byte("TIT2\x00\x00\x00\x01\x00\x00\x01"))
+	if text := frame.(TextInformationFrame).Text; text != "" {
+		t.Errorf("expected empty text, got %q", text)
+	}
+}
+
+func TestFilterFrames(t *testing.T) {
+	tag := NewTag()
+	tag.SetTitle("Title")
+	tag.SetArtists([]string{"Artist"})
+	tag.SetTextFrame("TXXX:Key", "Value")
+	tag.SetArtistURL("https://example.com")
+	tag.SetComments([]Comment{{Language: "eng", Text: "Comment"}})
+
+	var ids []FrameType
+	for _, frame := range tag.FilterFrames(IsTextFrame) {
+		ids = append(ids, frame.ID())
+	}
+	if !reflect.DeepEqual(ids, []FrameType{"TIT2", "TPE1", "TXXX"}) {
+		t.Errorf("unexpected text frames %v", ids)
+	}
+
+	urls := tag.FilterFrames(IsURLFrame)
+	if len(urls) != 1 || urls[0].Value() != "https://example.com" {
+		t.Errorf("unexpected URL frames %v", urls)
+	}
+}
+
+func TestWriteTagInto(t *testing.T) {
+	tag := NewTag()
+	tag.SetTitle("Title")
+	enc := NewEncoder(ioutil.Discard)
+	enc.Padding = 0
+	n, err := enc.WriteTagN(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, available := range []int{n, n + 100} {
+		buf := &bytes.Buffer{}
+		if err := NewEncoder(buf).WriteTagInto(tag, available); err != nil {
+			t.Fatalf("%d bytes available: %s", available, err)
+		}
+		if buf.Len() != available {
+			t.Errorf("expected %d bytes to be written, got %d", available, buf.Len())
+		}
+		d := NewDecoder(buf)
+		out, err := d.Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Title() != "Title" || d.Padding() != available-n {
+			t.Errorf("expected title and %d bytes of padding, got %q and %d", available-n, out.Title(), d.Padding())
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	err = NewEncoder(buf).WriteTagInto(tag, n-1)
+	if err, ok := err.(TagTooLargeError); !ok || err.Size != n || err.Available != n-1 {
+		t.Errorf("expected TagTooLargeError, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing to be written, got %d bytes", buf.Len())
+	}
+}
+
+func TestDecoderUpgrade(t *testing.T) {
+	b := []byte("ID3\x03\x00\x00\x00\x00\x00\x2D" +
+		"TYER\x00\x00\x00\x05\x00\x00\x002005" +
+		"TDAT\x00\x00\x00\x05\x00\x00\x001503" +
+		"TIME\x00\x00\x00\x05\x00\x00\x001230")
+
+	d := NewDecoder(bytes.NewReader(b))
+	d.Upgrade = false
+	tag, err := d.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.GetTextFrame("TYER") != "2005" || tag.GetTextFrame("TDAT") != "1503" || tag.GetTextFrame("TIME") != "1230" {
+		t.Errorf("expected original frames to be kept, got %v", tag)
+	}
+	if tag.HasFrame("TDRC") {
+		t.Error("didn't expect TDRC")
+	}
+
+	tag, err = NewDecoder(bytes.NewReader(b)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.HasFrame("TYER") || tag.HasFrame("TDAT") || tag.HasFrame("TIME") {
+		t.Errorf("expected frames to be upgraded, got %v", tag)
+	}
+	want := time.Date(2005, 3, 15, 12, 30, 0, 0, time.UTC)
+	if got := tag.RecordingTime(); !got.Equal(want) {
+		t.Errorf("expected recording time %s, got %s", want, got)
+	}
+
+	tests := []struct {
+		frames map[FrameType]string
+		tdrc   string
+		p      Precision
+	}{
+		{map[FrameType]string{"TYER": "2009"}, "2009", PrecisionYear},
+		{map[FrameType]string{"TYER": "2009", "TDAT": "1503"}, "2009-03-15", PrecisionDay},
+		{map[FrameType]string{"TYER": "2009", "TDAT": "1503", "TIME": "1230"}, "2009-03-15T12:30", PrecisionMinute},
+		// A time without a date is meaningless.
+		{map[FrameType]string{"TYER": "2009", "TIME": "1230"}, "2009", PrecisionYear},
+		// A date without a year can't be represented.
+		{map[FrameType]string{"TDAT": "1503"}, "", 0},
+		{map[FrameType]string{"TDAT": "1503", "TIME": "1230"}, "", 0},
+		// Malformed dates and times are ignored.
+		{map[FrameType]string{"TYER": "2009", "TDAT": "0000"}, "2009", PrecisionYear},
+		{map[FrameType]string{"TYER": "2009", "TDAT": "3102", "TIME": "1230"}, "2009", PrecisionYear},
+		{map[FrameType]string{"TYER": "2009", "TDAT": "1503", "TIME": "2460"}, "2009-03-15", PrecisionDay},
+	}
+	for _, test := range tests {
+		tag := NewTag()
+		for id, value := range test.frames {
+			tag.SetTextFrame(id, value)
+		}
+		tag.upgrade()
+		if got := tag.GetTextFrame("TDRC"); got != test.tdrc {
+			t.Errorf("%v: expected TDRC %q, got %q", test.frames, test.tdrc, got)
+		}
+		if p := tag.RecordingTimePrecision(); p != test.p {
+			t.Errorf("%v: expected precision %d, got %d", test.frames, test.p, p)
+		}
+		if tag.HasFrame("TYER") || tag.HasFrame("TDAT") || tag.HasFrame("TIME") {
+			t.Errorf("%v: expected v2.3 date frames to be removed, got %v", test.frames, tag)
+		}
+	}
+}
+
+func TestOrderedFrames(t *testing.T) {
+	tag := NewTag()
+	tag.SetComments([]Comment{{Language: "eng", Text: "Comment"}})
+	tag.SetAlbum("Album")
+	tag.SetTextFrame("TRCK", "1/9")
+	tag.SetArtistURL("https://example.com")
+	tag.SetTitle("Title")
+	tag.SetTextFrame("TXXX:Key", "Value")
+	tag.Frames["UFID"] = []Frame{UniqueFileIdentifierFrame{
+		FrameHeader: FrameHeader{id: "UFID"},
+		Owner:       "http://example.com",
+		Identifier:  []byte("1"),
+	}}
+
+	var ids []FrameType
+	for _, frame := range tag.OrderedFrames() {
+		ids = append(ids, frame.ID())
+	}
+	want := []FrameType{"UFID", "TIT2", "TRCK", "TALB", "TXXX", "COMM", "WOAR"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("expected order %v, got %v", want, ids)
+	}
+}
+
+func TestSynchsafeInt(t *testing.T) {
+	tests := []struct {
+		in  [4]byte
+		out int
+	}{
+		{[4]byte{0, 0, 0, 0}, 0},
+		{[4]byte{0, 0, 1, 0x7F}, 255},
+		{[4]byte{1, 0, 0, 0}, 1 << 21},
+		{[4]byte{0x7F, 0x7F, 0x7F, 0x7F}, maxTagSize},
+	}
+	for _, test := range tests {
+		if out := desynchsafeInt(test.in); out != test.out {
+			t.Errorf("%v: expected %d, got %d", test.in, test.out, out)
+		}
+		if out := intToBytes(synchsafeInt(test.out)); !bytes.Equal(out, test.in[:]) {
+			t.Errorf("%d: expected %v, got %v", test.out, test.in, out)
+		}
+	}
+
+	for i := 0; i <= maxTagSize; i += 4099 {
+		b := intToBytes(synchsafeInt(i))
+		if out := desynchsafeInt([4]byte{b[0], b[1], b[2], b[3]}); out != i {
+			t.Fatalf("%d: got %d after round trip", i, out)
+		}
+	}
+
+	enc := NewEncoder(ioutil.Discard)
+	if err := enc.WriteHeader(maxTagSize); err != nil {
+		t.Errorf("unexpected error for maximum size: %s", err)
+	}
+	if _, ok := enc.WriteHeader(maxTagSize + 1).(TagTooLargeError); !ok {
+		t.Error("expected TagTooLargeError for size above maximum")
+	}
+	enc.Padding = maxTagSize
+	if _, ok := enc.WriteTag(NewTag()).(TagTooLargeError); !ok {
+		t.Error("expected TagTooLargeError from WriteTag")
+	}
+	enc.Buffered()
+	if err := enc.WriteFrame(NewTextFrame("TIT2", "Title")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := enc.Flush().(TagTooLargeError); !ok {
+		t.Error("expected TagTooLargeError from Flush")
+	}
+
+	f := hugeFrame{UnsupportedFrame{FrameHeader: FrameHeader{id: "XXXX"}}}
+	if err, ok := enc.WriteFrame(f).(TagTooLargeError); !ok || err.Size != 10+f.Size() {
+		t.Errorf("expected TagTooLargeError for oversized frame, got %v", err)
+	}
+}
+
+// hugeFrame claims to be too large for a tag without allocating the
+// memory.
+type hugeFrame struct {
+	UnsupportedFrame
+}
+
+func (hugeFrame) Size() int { return frameLength + maxTagSize + 1 }
+
+func TestGenres(t *testing.T) {
+	tag := NewTag()
+	tag.SetGenres([]string{"Rock", "Metal"})
+	if got := tag.GetTextFrame("TCON"); got != "Rock\x00Metal" {
+		t.Errorf("expected null-separated genres, got %q", got)
+	}
+	tag.SetGenres([]string{"Remix", "Jazz", "Cover", "Remixed Cover Jazz"})
+	if got := tag.GetTextFrame("TCON"); got != "RX\x00Jazz\x00CR\x00Remixed Cover Jazz" {
+		t.Errorf("expected keywords for Remix and Cover, got %q", got)
+	}
+	if got := tag.Genres(); !reflect.DeepEqual(got, []string{"Remix", "Jazz", "Cover", "Remixed Cover Jazz"}) {
+		t.Errorf("expected keywords to be expanded, got %q", got)
+	}
+
+	v23 := []struct {
+		in  []string
+		out string
+	}{
+		{[]string{"Rock", "Metal"}, "(17)(9)"},
+		{[]string{"Rock", "Black Metal"}, "(17)Black Metal"},
+		{[]string{"Black", "Rock", "Death"}, "(17)Black/Death"},
+		{[]string{"(Parenthesised)"}, "((Parenthesised)"},
+		{[]string{"Remix", "Jazz"}, "(RX)(8)"},
+		{[]string{"Cover", "Remixed Cover Jazz"}, "(CR)Remixed Cover Jazz"},
+		{nil, ""},
+	}
+	for _, test := range v23 {
+		if got := formatV23Genres(test.in); got != test.out {
+			t.Errorf("%q: expected v2.3 genres %q, got %q", test.in, test.out, got)
+		}
+	}
+
+	tests := []struct {
+		in  string
+		out []string
+	}{
+		{"(17)(9)", []string{"Rock", "Metal"}},
+		{"(17)Rock", []string{"Rock"}},
+		{"(9)Black Metal", []string{"Metal", "Black Metal"}},
+		{"(RX)(CR)", []string{"Remix", "Cover"}},
+		{"((Parenthesised)", []string{"(Parenthesised)"}},
+		{"Rock/Pop", []string{"Rock", "Pop"}},
+		{"17", []string{"Rock"}},
+	}
+	for _, test := range tests {
+		tag := NewTag()
+		tag.SetTextFrame("TCON", test.in)
+		tag.upgrade()
+		if got := tag.Genres(); !reflect.DeepEqual(got, test.out) {
+			t.Errorf("%q: expected %q, got %q", test.in, test.out, got)
+		}
+	}
+}
+
+func TestEncoderBuffered(t *testing.T) {
+	tag := NewTag()
+	tag.SetTitle("Title")
+	tag.SetArtist("Artist")
+	expected := &bytes.Buffer{}
+	if err := NewEncoder(expected).WriteTag(tag); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	enc.Buffered()
+	for _, frame := range tag.OrderedFrames() {
+		if err := enc.WriteFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing to be written before Flush, got %d bytes", buf.Len())
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), expected.Bytes()) {
+		t.Errorf("buffered output differs from WriteTag:\n%q\n%q", buf.Bytes(), expected.Bytes())
+	}
+
+	if err := enc.Flush(); err != ErrNotBuffered {
+		t.Errorf("expected ErrNotBuffered, got %v", err)
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	for code, valid := range map[string]bool{"eng": true, "ger": true, "deu": true, "en": false, "zzz": false, "ENG": false} {
+		if ValidLanguage(code) != valid {
+			t.Errorf("%q: expected valid = %t", code, valid)
+		}
+	}
+	for in, out := range map[string]string{"eng": "eng", "en": "eng", "DE": "ger", "Fra": "fra", "zzz": "zzz"} {
+		if got := NormalizeLanguage(in); got != out {
+			t.Errorf("%q: expected %q, got %q", in, out, got)
+		}
+	}
+
+	tag := NewTag()
+	tag.SetComments([]Comment{{Language: "en", Text: "Comment"}, {Language: "zzz", Text: "Other"}})
+	tag.SetLanguages([]string{"de"})
+	err, ok := tag.Validate().(ValidationError)
+	if !ok || len(err.Problems) != 3 {
+		t.Fatalf("expected three problems, got %v", err)
+	}
+	for _, p := range err.Problems {
+		if !p.Warning {
+			t.Errorf("expected only warnings, got %s", p)
+		}
+	}
+
+	tag.Sanitize()
+	comments := tag.Comments()
+	if len(comments) != 2 || comments[0].Language != "eng" || comments[1].Language != "zzz" {
+		t.Errorf("unexpected comments after sanitizing: %v", comments)
+	}
+	if tag.Language() != "ger" {
+		t.Errorf("expected language ger, got %q", tag.Language())
+	}
+
+	tag.SetComment(Comment{Language: "EN", Text: "Replaced"})
+	if comments := tag.Comments(); len(comments) != 2 || comments[0].Text != "Replaced" {
+		t.Errorf("expected SetComment to normalize the language, got %v", comments)
+	}
+}
+
+func TestRecordingTimePrecision(t *testing.T) {
+	tag := NewTag()
+	if p := tag.RecordingTimePrecision(); p != 0 {
+		t.Errorf("expected no precision for missing frame, got %d", p)
+	}
+
+	tag.SetTextFrame("TDRC", "2009")
+	if p := tag.RecordingTimePrecision(); p != PrecisionYear {
+		t.Errorf("expected year precision, got %d", p)
+	}
+	tag.SetTextFrame("TDRC", "2009-11")
+	if p := tag.RecordingTimePrecision(); p != PrecisionMonth {
+		t.Errorf("expected month precision, got %d", p)
+	}
+
+	ts := time.Date(2009, 11, 10, 23, 4, 5, 0, time.UTC)
+	tag.SetRecordingTime(ts)
+	if p := tag.RecordingTimePrecision(); p != PrecisionSecond {
+		t.Errorf("expected second precision, got %d", p)
+	}
+
+	tag.SetRecordingTimeWithPrecision(ts, PrecisionYear)
+	if got := tag.GetTextFrame("TDRC"); got != "2009" {
+		t.Errorf("expected %q, got %q", "2009", got)
+	}
+	tag.SetRecordingTimeWithPrecision(ts, PrecisionMinute)
+	if got := tag.GetTextFrame("TDRC"); got != "2009-11-10T23:04" {
+		t.Errorf("expected %q, got %q", "2009-11-10T23:04", got)
+	}
+}
+
+func TestUTF16ToUTF8WithoutBOM(t *testing.T) {
+	tests := []struct {
+		in  []byte
+		out string
+	}{
+		{[]byte("T\x00e\x00s\x00t\x00"), "Test"},
+		{[]byte("\x00T\x00e\x00s\x00t"), "Test"},
+		{[]byte("\x00T\x00e\x00\x00\x00s\x00t"), "Te\x00st"},
+		// Inconclusive, so big endian
+		{[]byte("\x4e\x2d\x65\x87"), "中文"},
+	}
+	for _, test := range tests {
+		if out := utf16ToUTF8(test.in); string(out) != test.out {
+			t.Errorf("%q: expected %q, got %q", test.in, test.out, out)
+		}
+	}
+
+	// UTF-16BE is never guessed to be little endian, even if it
+	// looks like it.
+	if out := Encoding(utf16be).toUTF8([]byte("\x4e\x00")); string(out) != "一" {
+		t.Errorf("expected UTF-16BE %q, got %q", "一", out)
+	}
+	if out := Encoding(utf16bom).toUTF8([]byte("\x4e\x00")); string(out) != "N" {
+		t.Errorf("expected guessed little endian %q, got %q", "N", out)
+	}
+}
+
+func TestHeaderTotalSize(t *testing.T) {
+	tag := NewTag()
+	tag.SetTitle("Title")
+	buf := &bytes.Buffer{}
+	if err := NewEncoder(buf).WriteTag(tag); err != nil {
+		t.Fatal(err)
+	}
+	n := buf.Len()
+	buf.WriteString("audio")
+
+	h, err := NewDecoder(buf).ParseHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.TotalSize() != n {
+		t.Errorf("expected total size %d, got %d", n, h.TotalSize())
+	}
+
+	h.Flags |= 16
+	if h.TotalSize() != n+10 {
+		t.Errorf("expected footer to be included, got %d", h.TotalSize())
+	}
+	h.Version = 0x0300
+	if h.TotalSize() != n {
+		t.Errorf("didn't expect footer in ID3v2.3 tag, got %d", h.TotalSize())
+	}
+}
+
+func TestUndecodableFrameAccessors(t *testing.T) {
+	// Grouped frames, with group symbol 5
+	frames := "TIT2\x00\x00\x00\x04\x00\x40\x05\x03ab" +
+		"TXXX\x00\x00\x00\x05\x00\x40\x05\x03a\x00b" +
+		"COMM\x00\x00\x00\x07\x00\x40\x05\x03eng\x00c" +
+		"UFID\x00\x00\x00\x04\x00\x40\x05o\x00i" +
+		"WXXX\x00\x00\x00\x05\x00\x40\x05\x03d\x00u"
+	b := concat(generateHeader(len(frames), 0), []byte(frames))
+	tag, err := NewDecoder(bytes.NewReader(b)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s, ok := tag.LookupTextFrame("TIT2"); ok || s != "" {
+		t.Errorf("expected no title, got %q", s)
+	}
+	if s, ok := tag.LookupTextFrame("TXXX:a"); ok || s != "" {
+		t.Errorf("expected no user text frame, got %q", s)
+	}
+	if n := len(tag.UserTextFrames()); n != 0 {
+		t.Errorf("expected no user text frames, got %d", n)
+	}
+	if n := len(tag.Comments()); n != 0 {
+		t.Errorf("expected no comments, got %d", n)
+	}
+	if _, ok := tag.UniqueFileID("o"); ok {
+		t.Error("expected no unique file identifier")
+	}
+	if s := tag.UserURL("d"); s != "" {
+		t.Errorf("expected no user URL, got %q", s)
+	}
+	if _, _, ok := tag.ReplayGainTrack(); ok {
+		t.Error("expected no replay gain")
+	}
+
+	tag.SetTextFrame("TXXX:a", "new")
+	tag.SetComment(Comment{Language: "eng", Text: "new"})
+	tag.SetUniqueFileID("o", []byte("new"))
+	tag.SetUserURL("d", "new")
+	if got := tag.GetTextFrame("TXXX:a"); got != "new" {
+		t.Errorf("expected %q, got %q", "new", got)
+	}
+	if c, ok := tag.Comment("eng", ""); !ok || c.Text != "new" {
+		t.Errorf("expected new comment, got %v", c)
+	}
+	if n := tag.RemoveUserTextFrames(""); n != 1 {
+		t.Errorf("expected 1 removed frame, got %d", n)
+	}
+
+	// The undecodable frames are written back as they are.
+	buf := &bytes.Buffer{}
+	if err := NewEncoder(buf).WriteTag(tag); err != nil {
+		t.Fatal(err)
+	}
+	for _, frame := range []string{
+		"TIT2\x00\x00\x00\x04\x00\x40\x05\x03ab",
+		"TXXX\x00\x00\x00\x05\x00\x40\x05\x03a\x00b",
+		"COMM\x00\x00\x00\x07\x00\x40\x05\x03eng\x00c",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(frame)) {
+			t.Errorf("expected %q to be written", frame)
+		}
+	}
+}
+
+func TestDecryptor(t *testing.T) {
+	xor := func(data []byte) ([]byte, error) {
+		out := make([]byte, len(data))
+		for i, c := range data {
+			out[i] = c ^ 0x55
+		}
+		return out, nil
+	}
+	encrypted, _ := xor([]byte("\x03Title"))
+	b := concat([]byte("TIT2\x00\x00\x00\x07\x00\x04\x80"), encrypted)
+
+	parse := func(d *Decoder) Frame {
+		d.r = io.LimitReader(bytes.NewReader(b), int64(len(b)))
+		d.h.Version = 0x0400
+		frame, err := d.ParseFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return frame
+	}
+
+	if frame, ok := parse(NewDecoder(nil)).(UnsupportedFrame); !ok || !bytes.Equal(frame.Data, b[10:]) {
+		t.Errorf("expected encrypted frame to be unsupported without a decryptor, got %v", frame)
+	}
+
+	d := NewDecoder(nil)
+	d.RegisterDecryptor(0x80, xor)
+	frame, ok := parse(d).(TextInformationFrame)
+	if !ok || frame.Text != "Title" {
+		t.Fatalf("expected decrypted TIT2, got %v", frame)
+	}
+	if frame.Flags().Encrypted() {
+		t.Error("expected encryption flag to be cleared")
+	}
+}
+
+func TestCompressedFrames(t *testing.T) {
+	pic := PictureFrame{
+		FrameHeader: FrameHeader{id: "APIC"},
+		MIMEType:    "image/png",
+		PictureType: 3,
+		Description: "Cover",
+		Data:        bytes.Repeat([]byte("compressible "), 100),
+	}
+	tag := NewTag()
+	tag.Frames["APIC"] = []Frame{pic}
+	tag.SetTitle("Title")
+
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	enc.Compress = func(id FrameType) bool { return id == "APIC" }
+	if err := enc.WriteTag(tag); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > pic.Size() {
+		t.Errorf("expected tag to be smaller than the uncompressed picture, got %d bytes", buf.Len())
+	}
+
+	out, err := NewDecoder(buf).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Title() != "Title" {
+		t.Errorf("expected title %q, got %q", "Title", out.Title())
+	}
+	frames := out.Frames["APIC"]
+	if len(frames) != 1 {
+		t.Fatalf("expected one picture, got %d", len(frames))
+	}
+	if got, ok := frames[0].(PictureFrame); !ok || !FramesEqual(got, pic) {
+		t.Errorf("expected %v, got %v", pic, frames[0])
+	}
+}
+
+func TestCopyFrom(t *testing.T) {
+	src := NewTag()
+	src.SetTitle("Title")
+	src.Frames["APIC"] = []Frame{PictureFrame{
+		FrameHeader: FrameHeader{id: "APIC"},
+		MIMEType:    "image/png",
+		Data:        []byte("data"),
+	}}
+
+	dst := NewTag()
+	dst.SetAlbum("Album")
+	dst.CopyFrom(src)
+	if dst.HasFrame("TALB") || dst.Title() != "Title" {
+		t.Errorf("expected frames to be replaced, got %v", dst)
+	}
+
+	pic := dst.Frames["APIC"][0].(PictureFrame)
+	pic.Data[0] = 'D'
+	dst.SetTitle("Other")
+	if data := src.Frames["APIC"][0].(PictureFrame).Data; string(data) != "data" {
+		t.Errorf("modifying the copy changed the original picture to %q", data)
+	}
+	if src.Title() != "Title" {
+		t.Errorf("modifying the copy changed the original title to %q", src.Title())
+	}
+}
+
+func TestTagUpdate(t *testing.T) {
+	ext := ExtendedHeader{Update: true}.encode()
+	frame := []byte("TIT2\x00\x00\x00\x06\x00\x00\x03Title")
+	b := concat(generateHeader(len(ext)+len(frame), 0x40), ext, frame)
+
+	d := NewDecoder(bytes.NewReader(b))
+	tag, err := d.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Header().IsUpdate || !d.ExtendedHeader().Update {
+		t.Error("expected tag to be reported as an update")
+	}
+	if tag.Title() != "Title" {
+		t.Errorf("expected title %q, got %q", "Title", tag.Title())
+	}
+
+	h, err := NewDecoder(bytes.NewReader(b)).ParseHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.IsUpdate {
+		t.Error("expected ParseHeader to report the update flag")
+	}
+}
+
+func TestExtendedHeaderTooLarge(t *testing.T) {
+	frame := []byte("TIT2\x00\x00\x00\x06\x00\x00\x03Title")
+	for _, ext := range [][]byte{
+		// v2.4, synchsafe size including the size field
+		[]byte("\x7f\x7f\x7f\x7f\x01\x00"),
+		// size one byte larger than the tag
+		concat(intToBytes(synchsafeInt(6+len(frame)+1)), []byte{1, 0}),
+	} {
+		b := concat(generateHeader(len(ext)+len(frame), 0x40), ext, frame)
+		_, err := NewDecoder(bytes.NewReader(b)).ParseHeader()
+		if err != ErrInvalidExtendedHeader {
+			t.Errorf("expected ErrInvalidExtendedHeader, got %v", err)
+		}
+	}
+}
+
+func TestDedupTextFrames(t *testing.T) {
+	frames := "TALB\x00\x00\x00\x06\x00\x00\x03First" +
+		"TALB\x00\x00\x00\x07\x00\x00\x03Second" +
+		"TXXX\x00\x00\x00\x04\x00\x00\x03a\x00b" +
+		"TXXX\x00\x00\x00\x04\x00\x00\x03a\x00c" +
+		"TXXX\x00\x00\x00\x04\x00\x00\x03b\x00c"
+	b := concat(generateHeader(len(frames), 0), []byte(frames))
+
+	tag, err := NewDecoder(bytes.NewReader(b)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tag.Frames["TALB"]) != 2 {
+		t.Errorf("expected duplicates to be kept by default, got %d frames", len(tag.Frames["TALB"]))
+	}
+
+	d := NewDecoder(bytes.NewReader(b))
+	d.DedupTextFrames = true
+	tag, err = d.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames := tag.Frames["TALB"]; len(frames) != 1 || frames[0].Value() != "First" {
+		t.Errorf("expected only the first TALB to survive, got %v", frames)
+	}
+	if frames := tag.Frames["TXXX"]; len(frames) != 2 || tag.GetTextFrame("TXXX:a") != "b" {
+		t.Errorf("expected one TXXX per description, got %v", frames)
+	}
+}
+
+func TestFrameConstructors(t *testing.T) {
+	frames := []Frame{
+		NewTextFrame("TIT2", "Title"),
+		NewUserTextFrame("Key", "Value"),
+		NewURLFrame("WOAR", "https://example.com"),
+		NewUserURLFrame("Home", "https://example.com"),
+		NewCommentFrame("eng", "", "Comment"),
+		NewLyricsFrame("eng", "", "Lyrics"),
+		NewPictureFrame("image/png", 3, "Cover", []byte("data")),
+		NewPrivateFrame([]byte("owner"), []byte("data")),
+		NewUniqueFileIdentifierFrame("http://example.com", []byte("1")),
+		NewTermsOfUseFrame("eng", "Terms"),
+	}
+	for _, frame := range frames {
+		if _, ok := FrameNames[frame.ID()]; !ok {
+			t.Errorf("%T has unknown ID %q", frame, frame.ID())
+			continue
+		}
+		out := roundTripFrame(t, frame)
+		if !FramesEqual(out, frame) {
+			t.Errorf("expected %v, got %v", frame, out)
+		}
+	}
+}
+
+func TestTrimText(t *testing.T) {
+	b := []byte("TIT2\x00\x00\x00\x09\x00\x00\x03Title\x00\x00 " +
+		"TPE1\x00\x00\x00\x06\x00\x00\x03A \x00B\x00" +
+		"TXXX\x00\x00\x00\x07\x00\x00\x03Key\x00V ")
+	for _, trim := range []bool{false, true} {
+		d := NewDecoder(bytes.NewReader(b))
+		d.r = io.LimitReader(d.r, int64(len(b)))
+		d.h.Version = 0x0400
+		d.TrimText = trim
+
+		var values []string
+		for {
+			frame, err := d.ParseFrame()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			values = append(values, frame.Value())
+		}
+
+		want := []string{"Title\x00\x00 ", "A \x00B", "V "}
+		if trim {
+			want = []string{"Title", "A\x00B", "V"}
+		}
+		if !reflect.DeepEqual(values, want) {
+			t.Errorf("TrimText = %t: expected %q, got %q", trim, want, values)
+		}
+	}
+}
+
+func TestReleaseTime(t *testing.T) {
+	tag := NewTag()
+	tag.SetReleaseTimeWithPrecision(time.Date(2009, 11, 10, 0, 0, 0, 0, time.UTC), PrecisionYear)
+
+	buf := &bytes.Buffer{}
+	if err := NewEncoder(buf).WriteTag(tag); err != nil {
+		t.Fatal(err)
+	}
+	out, err := NewDecoder(buf).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out.GetTextFrame("TDRL"); got != "2009" {
+		t.Errorf("expected %q, got %q", "2009", got)
+	}
+	if p := out.ReleaseTimePrecision(); p != PrecisionYear {
+		t.Errorf("expected year precision, got %d", p)
+	}
+	if got := out.ReleaseTime(); !got.Equal(time.Date(2009, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected release time %s", got)
+	}
+
+	// Invalid timestamps don't panic.
+	out.SetTextFrame("TDRL", "sometime")
+	if got := out.ReleaseTime(); !got.IsZero() || out.ReleaseTimePrecision() != 0 {
+		t.Errorf("expected zero time for invalid timestamp, got %s", got)
+	}
+}
+
+// tagWithPicture returns an encoded tag with a title, an artist and a
+// large picture.
+func tagWithPicture(tb testing.TB) []byte {
+	tag := NewTag()
+	tag.SetTitle("Title")
+	tag.SetArtist("Artist")
+	tag.SetAlbum("Album")
+	tag.Frames["APIC"] = []Frame{NewPictureFrame("image/jpeg", 3, "", make([]byte, 1<<20))}
+	buf := &bytes.Buffer{}
+	if err := NewEncoder(buf).WriteTag(tag); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseSelective(t *testing.T) {
+	b := tagWithPicture(t)
+	d := NewDecoder(bytes.NewReader(b))
+	tag, err := d.ParseSelective("TIT2", "TPE1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tag.Frames) != 2 || tag.Title() != "Title" || tag.Artist() != "Artist" {
+		t.Errorf("expected only title and artist, got %v", tag)
+	}
+	if d.Padding() != 1024 {
+		t.Errorf("expected the entire tag to be consumed, got padding %d", d.Padding())
+	}
+}
+
+func TestParseSelectiveUpgrade(t *testing.T) {
+	frames := "TYER\x00\x00\x00\x05\x00\x00\x002005" +
+		"TDAT\x00\x00\x00\x05\x00\x00\x001503" +
+		"TORY\x00\x00\x00\x05\x00\x00\x001999" +
+		"TIT2\x00\x00\x00\x06\x00\x00\x00Title"
+	b := concat([]byte("ID3\x03\x00\x00\x00\x00\x00"+string(rune(len(frames)))), []byte(frames))
+
+	tag, err := NewDecoder(bytes.NewReader(b)).ParseSelective("TDRC", "TDOR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tag.Frames) != 2 {
+		t.Errorf("expected only TDRC and TDOR, got %v", tag)
+	}
+	if got := tag.GetTextFrame("TDRC"); got != "2005-03-15" {
+		t.Errorf("expected TDRC %q, got %q", "2005-03-15", got)
+	}
+	if got := tag.GetTextFrame("TDOR"); got != "1999" {
+		t.Errorf("expected TDOR %q, got %q", "1999", got)
+	}
+
+	d := NewDecoder(bytes.NewReader(b))
+	d.Upgrade = false
+	tag, err = d.ParseSelective("TDRC", "TDOR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tag.Frames) != 0 {
+		t.Errorf("expected no frames without upgrading, got %v", tag)
+	}
+}
+
+func TestTruncatedTag(t *testing.T) {
+	frames := "TIT2\x00\x00\x00\x06\x00\x00\x03Title" +
+		"TPE1\x00\x00\x00\x07\x00\x00\x03Artist" +
+		"TALB\x00\x00\x00\x06\x00\x00\x03Album"
+	b := concat(generateHeader(len(frames)+100, 0), []byte(frames))
+	// Cut off in the middle of the third frame
+	b = b[:len(b)-3]
+
+	tag, err := NewDecoder(bytes.NewReader(b)).Parse()
+	terr, ok := err.(TruncatedTagError)
+	if !ok {
+		t.Fatalf("expected TruncatedTagError, got %v", err)
+	}
+	if terr.Expected != len(frames)+100 || terr.Available != len(frames)-3 {
+		t.Errorf("unexpected sizes in %v", terr)
+	}
+	if tag.Title() != "Title" || tag.Artist() != "Artist" || tag.HasFrame("TALB") {
+		t.Errorf("expected the first two frames, got %v", tag)
+	}
+}
+
+// roundTrip writes t with a new Encoder and parses it back. The
+// tagging time that the Encoder adds is removed from both tags.
+func roundTrip(t *Tag) (*Tag, error) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	enc.PreserveEncoding = true
+	if err := enc.WriteTag(t); err != nil {
+		return nil, err
+	}
+	delete(t.Frames, "TDTG")
+	out, err := NewDecoder(buf).Parse()
+	if err != nil {
+		return nil, err
+	}
+	delete(out.Frames, "TDTG")
+	return out, nil
+}
+
+func TestRoundTrip(t *testing.T) {
+	h := func(id FrameType) FrameHeader { return FrameHeader{id: id} }
+	frames := []Frame{
+		TextInformationFrame{FrameHeader: h("TIT2"), Text: "Tïtle\x00♫", Encoding: utf8},
+		TextInformationFrame{FrameHeader: h("TIT3"), Text: "Tïtle\x00Other", Encoding: iso88591},
+		TextInformationFrame{FrameHeader: h("TPE1"), Text: "Ärtist\x00♫", Encoding: utf16bom},
+		TextInformationFrame{FrameHeader: h("TPE2"), Text: "Bänd", Encoding: utf16be},
+		UserTextInformationFrame{FrameHeader: h("TXXX"), Description: "Désc", Text: "Tëxt"},
+		UniqueFileIdentifierFrame{FrameHeader: h("UFID"), Owner: "http://exämple.com", Identifier: []byte("\x00\xffid")},
+		URLLinkFrame{FrameHeader: h("WOAR"), URL: "http://example.com"},
+		UserDefinedURLLinkFrame{FrameHeader: h("WXXX"), Description: "Désc", URL: "http://example.com"},
+		CommentFrame{FrameHeader: h("COMM"), Language: "eng", Description: "Désc", Text: "Tëxt ♫"},
+		PrivateFrame{FrameHeader: h("PRIV"), Owner: []byte("owner"), Data: []byte("\x00\x01\xff")},
+		PictureFrame{FrameHeader: h("APIC"), MIMEType: "image/png", PictureType: 3, Description: "Désc ♫", Data: []byte("\x89PNG\x00\xff")},
+		MusicCDIdentifierFrame{FrameHeader: h("MCDI"), TOC: []byte("\x00\x01toc")},
+		UnsynchronisedLyricsFrame{FrameHeader: h("USLT"), Language: "deu", Description: "Désc", Lyrics: "Lÿrics\nZweite Zeile ♫"},
+		RelativeVolumeAdjustmentFrame{FrameHeader: h("RVA2"), Identification: "track", Adjustments: []VolumeAdjustment{{MasterVolume, -3.5, 0.5}, {FrontLeft, 1, 0}}},
+		EventTimingFrame{FrameHeader: h("ETCO"), TimestampFormat: Milliseconds, Events: []TimingEvent{{1, 1000}, {3, 0x01020304}}},
+		SynchronisedLyricsFrame{FrameHeader: h("SYLT"), Language: "eng", TimestampFormat: Milliseconds, ContentType: 1, Description: "Désc", Lines: []SyncedText{{"Lïne ♫", 100}}},
+		AudioSeekPointIndexFrame{FrameHeader: h("ASPI"), DataStart: 1, DataLength: 2, BitsPerPoint: 16, Points: []uint16{1, 0xffff}},
+		OwnershipFrame{FrameHeader: h("OWNE"), PricePaid: "EUR9.99", DatePurchased: "20200101", Seller: "Sëller ♫"},
+		CommercialFrame{FrameHeader: h("COMR"), Price: "EUR9.99", ValidUntil: "20200101", ContactURL: "http://example.com", ReceivedAs: 1, Seller: "Sëller", Description: "Désc", LogoMIMEType: "image/png", Logo: []byte("\x00logo")},
+		PositionSyncFrame{FrameHeader: h("POSS"), TimestampFormat: MPEGFrames, Position: 1},
+		RecommendedBufferFrame{FrameHeader: h("RBUF"), BufferSize: 1, EmbeddedInfo: true, NextFlagOffset: 2},
+		MPEGLookupTableFrame{FrameHeader: h("MLLT"), FramesBetweenReference: 1, BytesBetweenReference: 2, MillisecondsBetweenReference: 3, BitsForBytesDeviation: 4, BitsForMillisecondsDeviation: 4, References: []MPEGLookupReference{{1, 2}, {3, 4}, {5, 6}}},
+		GroupRegistrationFrame{FrameHeader: h("GRID"), Owner: "owner", GroupSymbol: 0x80, Data: []byte("\x00data")},
+		EncryptionRegistrationFrame{FrameHeader: h("ENCR"), Owner: "owner", MethodSymbol: 0x80, Data: []byte("\x00data")},
+		LinkFrame{FrameHeader: h("LINK"), FrameIdentifier: [4]byte{'C', 'O', 'M', 'M'}, URL: "http://example.com", IDAndData: []byte("eng")},
+		ReverbFrame{FrameHeader: h("RVRB"), Left: 1, Right: 2},
+		AudioEncryptionFrame{FrameHeader: h("AENC"), Owner: "owner", PreviewStart: 1, PreviewLength: 2, EncryptionInfo: []byte("\x00info")},
+		SignatureFrame{FrameHeader: h("SIGN"), GroupSymbol: 0x80, Signature: []byte("\x00sig")},
+		TermsOfUseFrame{FrameHeader: h("USER"), Language: "eng", Text: "Tërms ♫"},
+		UnsupportedFrame{FrameHeader: h("XXXX"), Data: []byte("\x00\xffdata")},
+	}
+
+	for _, f := range frames {
+		tag := NewTag()
+		tag.Frames[f.ID()] = []Frame{f}
+		out, err := roundTrip(tag)
+		if err != nil {
+			t.Errorf("%T: %s", f, err)
+			continue
+		}
+		if !reflect.DeepEqual(out.Frames, tag.Frames) {
+			t.Errorf("%T: expected %#v, got %#v", f, tag.Frames, out.Frames)
+		}
+	}
+}
+
+func TestRemoveUserTextFrames(t *testing.T) {
+	tag := NewTag()
+	tag.SetTextFrame("TXXX:replaygain_track_gain", "+3.21 dB")
+	tag.SetTextFrame("TXXX:replaygain_track_peak", "0.988235")
+	tag.SetTextFrame("TXXX:MusicBrainz Album Id", "f4f4f4f4-0000-0000-0000-000000000000")
+
+	if frames := tag.UserTextFramesWithPrefix("replaygain_"); len(frames) != 2 {
+		t.Errorf("expected 2 replaygain frames, got %v", frames)
+	}
+	if n := tag.RemoveUserTextFrames("replaygain_"); n != 2 {
+		t.Errorf("expected 2 removed frames, got %d", n)
+	}
+	frames := tag.UserTextFrames()
+	if len(frames) != 1 || frames[0].Description != "MusicBrainz Album Id" {
+		t.Errorf("expected only the MusicBrainz frame, got %v", frames)
+	}
+	if n := tag.RemoveUserTextFrames("replaygain_"); n != 0 {
+		t.Errorf("expected no removed frames, got %d", n)
+	}
+	tag.RemoveUserTextFrames("")
+	if tag.HasFrame("TXXX") {
+		t.Error("expected no TXXX frames")
+	}
+}
+
+func TestUnknownPictureType(t *testing.T) {
+	// UTF-16 description "d", picture type 0xFF
+	frame := "APIC\x00\x00\x00\x14\x00\x00" +
+		"\x01image/png\x00\xff\xff\xfed\x00\x00\x00\x89P"
+	b := concat(generateHeader(len(frame), 0), []byte(frame))
+
+	d := NewDecoder(bytes.NewReader(b))
+	tag, err := d.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tag.Frames["APIC"]) != 1 {
+		t.Fatalf("expected 1 picture, got %v", tag.Frames["APIC"])
+	}
+	pic := tag.Frames["APIC"][0].(PictureFrame)
+	if pic.PictureType != 0 || pic.MIMEType != "image/png" ||
+		pic.Description != "d" || !bytes.Equal(pic.Data, []byte("\x89P")) {
+		t.Errorf("unexpected picture %+v", pic)
+	}
+	warnings := d.Warnings()
+	if len(warnings) != 1 || warnings[0] != (PictureTypeError{"APIC", 0xFF}) {
+		t.Errorf("expected PictureTypeError, got %v", warnings)
+	}
+}
+
+func TestWriteTagAndBody(t *testing.T) {
+	tag := NewTag()
+	tag.SetTitle("Title")
+	audio := []byte("\xff\xfb\x90\x00audio data")
+
+	buf := &bytes.Buffer{}
+	n, err := NewEncoder(buf).WriteTagAndBody(tag, bytes.NewReader(audio))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("reported %d bytes, but wrote %d", n, buf.Len())
+	}
+
+	d := NewDecoder(bytes.NewReader(buf.Bytes()))
+	out, err := d.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Title() != "Title" {
+		t.Errorf("expected title %q, got %q", "Title", out.Title())
+	}
+	body := buf.Bytes()[d.Header().TotalSize():]
+	if !bytes.Equal(body, audio) {
+		t.Errorf("expected body %q, got %q", audio, body)
+	}
+}
+
+func TestOriginalReleaseTime(t *testing.T) {
+	tag := NewTag()
+	tag.SetTextFrame("TDOR", "2005")
+	if got, want := tag.OriginalReleaseTime(), time.Date(2005, 1, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if p := tag.OriginalReleaseTimePrecision(); p != PrecisionYear {
+		t.Errorf("expected year precision, got %v", p)
+	}
+
+	tag = NewTag()
+	tag.SetTextFrame("TORY", "2005")
+	tag.upgrade()
+	if got := tag.GetTextFrame("TDOR"); got != "2005" {
+		t.Errorf("expected TDOR %q, got %q", "2005", got)
+	}
+	if got := tag.OriginalReleaseTime().Year(); got != 2005 {
+		t.Errorf("expected year 2005, got %d", got)
+	}
+}
+
+func TestUpgradeOriginalReleaseYear(t *testing.T) {
+	b := []byte("ID3\x03\x00\x00\x00\x00\x00\x0F" +
+		"TORY\x00\x00\x00\x05\x00\x00\x002005")
+
+	tag, err := NewDecoder(bytes.NewReader(b)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tag.GetTextFrame("TDOR"); got != "2005" {
+		t.Errorf("expected TDOR %q, got %q", "2005", got)
+	}
+	if tag.HasFrame("TORY") {
+		t.Error("expected TORY to be removed")
+	}
+
+	tag = NewTag()
+	tag.SetTextFrame("XDOR", "2005-03-15")
+	tag.SetTextFrame("TORY", "2004")
+	tag.upgrade()
+	if got := tag.GetTextFrame("TDOR"); got != "2005-03-15" {
+		t.Errorf("expected TDOR %q, got %q", "2005-03-15", got)
+	}
+	if tag.HasFrame("XDOR") || tag.HasFrame("TORY") {
+		t.Error("expected XDOR and TORY to be removed")
+	}
+}
+
+func BenchmarkISO88591ToUTF8(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(ISOTestString)))
+	for i := 0; i < b.N; i++ {
+		_ = iso88591ToUTF8(ISOTestString)
+	}
+}
+
+func BenchmarkISO88591ToUTF8ASCII(b *testing.B) {
+	b.ReportAllocs()
+	input := []byte("A somewhat short text without any umlauts")
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		_ = iso88591ToUTF8(input)
+	}
+}
+
+func BenchmarkUTF8ToISO88591(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(UTF8TestString)))
+	for i := 0; i < b.N; i++ {
+		_ = utf8ToISO88591(UTF8TestString)
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	data := tagWithPicture(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewDecoder(bytes.NewReader(data)).Parse(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseSelective(b *testing.B) {
+	data := tagWithPicture(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewDecoder(bytes.NewReader(data)).ParseSelective("TIT2", "TPE1"); err != nil {
+			b.Fatal(err)
+		}
 	}
 }
 
 func BenchmarkUTF16ToUTF8(b *testing.B) {
+	b.ReportAllocs()
 	b.SetBytes(int64(len(UTF16TestString)))
 	for i := 0; i < b.N; i++ {
 		_ = utf16ToUTF8(UTF16TestString)
@@ -0,0 +1,150 @@
+package id3
+
+import "strings"
+
+// languages are the ISO 639-2 language codes, including both the
+// bibliographic and the terminology codes where they differ.
+var languages = map[string]bool{
+	"aar": true, "abk": true, "ace": true, "ach": true, "ada": true, "ady": true,
+	"afa": true, "afh": true, "afr": true, "ain": true, "aka": true, "akk": true,
+	"alb": true, "ale": true, "alg": true, "alt": true, "amh": true, "ang": true,
+	"anp": true, "apa": true, "ara": true, "arc": true, "arg": true, "arm": true,
+	"arn": true, "arp": true, "art": true, "arw": true, "asm": true, "ast": true,
+	"ath": true, "aus": true, "ava": true, "ave": true, "awa": true, "aym": true,
+	"aze": true, "bad": true, "bai": true, "bak": true, "bal": true, "bam": true,
+	"ban": true, "baq": true, "bas": true, "bat": true, "bej": true, "bel": true,
+	"bem": true, "ben": true, "ber": true, "bho": true, "bih": true, "bik": true,
+	"bin": true, "bis": true, "bla": true, "bnt": true, "bod": true, "bos": true,
+	"bra": true, "bre": true, "btk": true, "bua": true, "bug": true, "bul": true,
+	"bur": true, "byn": true, "cad": true, "cai": true, "car": true, "cat": true,
+	"cau": true, "ceb": true, "cel": true, "ces": true, "cha": true, "chb": true,
+	"che": true, "chg": true, "chi": true, "chk": true, "chm": true, "chn": true,
+	"cho": true, "chp": true, "chr": true, "chu": true, "chv": true, "chy": true,
+	"cmc": true, "cnr": true, "cop": true, "cor": true, "cos": true, "cpe": true,
+	"cpf": true, "cpp": true, "cre": true, "crh": true, "crp": true, "csb": true,
+	"cus": true, "cym": true, "cze": true, "dak": true, "dan": true, "dar": true,
+	"day": true, "del": true, "den": true, "deu": true, "dgr": true, "din": true,
+	"div": true, "doi": true, "dra": true, "dsb": true, "dua": true, "dum": true,
+	"dut": true, "dyu": true, "dzo": true, "efi": true, "egy": true, "eka": true,
+	"ell": true, "elx": true, "eng": true, "enm": true, "epo": true, "est": true,
+	"eus": true, "ewe": true, "ewo": true, "fan": true, "fao": true, "fas": true,
+	"fat": true, "fij": true, "fil": true, "fin": true, "fiu": true, "fon": true,
+	"fra": true, "fre": true, "frm": true, "fro": true, "frr": true, "frs": true,
+	"fry": true, "ful": true, "fur": true, "gaa": true, "gay": true, "gba": true,
+	"gem": true, "geo": true, "ger": true, "gez": true, "gil": true, "gla": true,
+	"gle": true, "glg": true, "glv": true, "gmh": true, "goh": true, "gon": true,
+	"gor": true, "got": true, "grb": true, "grc": true, "gre": true, "grn": true,
+	"gsw": true, "guj": true, "gwi": true, "hai": true, "hat": true, "hau": true,
+	"haw": true, "heb": true, "her": true, "hil": true, "him": true, "hin": true,
+	"hit": true, "hmn": true, "hmo": true, "hrv": true, "hsb": true, "hun": true,
+	"hup": true, "hye": true, "iba": true, "ibo": true, "ice": true, "ido": true,
+	"iii": true, "ijo": true, "iku": true, "ile": true, "ilo": true, "ina": true,
+	"inc": true, "ind": true, "ine": true, "inh": true, "ipk": true, "ira": true,
+	"iro": true, "isl": true, "ita": true, "jav": true, "jbo": true, "jpn": true,
+	"jpr": true, "jrb": true, "kaa": true, "kab": true, "kac": true, "kal": true,
+	"kam": true, "kan": true, "kar": true, "kas": true, "kat": true, "kau": true,
+	"kaw": true, "kaz": true, "kbd": true, "kha": true, "khi": true, "khm": true,
+	"kho": true, "kik": true, "kin": true, "kir": true, "kmb": true, "kok": true,
+	"kom": true, "kon": true, "kor": true, "kos": true, "kpe": true, "krc": true,
+	"krl": true, "kro": true, "kru": true, "kua": true, "kum": true, "kur": true,
+	"kut": true, "lad": true, "lah": true, "lam": true, "lao": true, "lat": true,
+	"lav": true, "lez": true, "lim": true, "lin": true, "lit": true, "lol": true,
+	"loz": true, "ltz": true, "lua": true, "lub": true, "lug": true, "lui": true,
+	"lun": true, "luo": true, "lus": true, "mac": true, "mad": true, "mag": true,
+	"mah": true, "mai": true, "mak": true, "mal": true, "man": true, "mao": true,
+	"map": true, "mar": true, "mas": true, "may": true, "mdf": true, "mdr": true,
+	"men": true, "mga": true, "mic": true, "min": true, "mis": true, "mkd": true,
+	"mkh": true, "mlg": true, "mlt": true, "mnc": true, "mni": true, "mno": true,
+	"moh": true, "mon": true, "mos": true, "mri": true, "msa": true, "mul": true,
+	"mun": true, "mus": true, "mwl": true, "mwr": true, "mya": true, "myn": true,
+	"myv": true, "nah": true, "nai": true, "nap": true, "nau": true, "nav": true,
+	"nbl": true, "nde": true, "ndo": true, "nds": true, "nep": true, "new": true,
+	"nia": true, "nic": true, "niu": true, "nld": true, "nno": true, "nob": true,
+	"nog": true, "non": true, "nor": true, "nqo": true, "nso": true, "nub": true,
+	"nwc": true, "nya": true, "nym": true, "nyn": true, "nyo": true, "nzi": true,
+	"oci": true, "oji": true, "ori": true, "orm": true, "osa": true, "oss": true,
+	"ota": true, "oto": true, "paa": true, "pag": true, "pal": true, "pam": true,
+	"pan": true, "pap": true, "pau": true, "peo": true, "per": true, "phi": true,
+	"phn": true, "pli": true, "pol": true, "pon": true, "por": true, "pra": true,
+	"pro": true, "pus": true, "que": true, "raj": true, "rap": true, "rar": true,
+	"roa": true, "roh": true, "rom": true, "ron": true, "rum": true, "run": true,
+	"rup": true, "rus": true, "sad": true, "sag": true, "sah": true, "sai": true,
+	"sal": true, "sam": true, "san": true, "sas": true, "sat": true, "scn": true,
+	"sco": true, "sel": true, "sem": true, "sga": true, "sgn": true, "shn": true,
+	"sid": true, "sin": true, "sio": true, "sit": true, "sla": true, "slk": true,
+	"slo": true, "slv": true, "sma": true, "sme": true, "smi": true, "smj": true,
+	"smn": true, "smo": true, "sms": true, "sna": true, "snd": true, "snk": true,
+	"sog": true, "som": true, "son": true, "sot": true, "spa": true, "sqi": true,
+	"srd": true, "srn": true, "srp": true, "srr": true, "ssa": true, "ssw": true,
+	"suk": true, "sun": true, "sus": true, "sux": true, "swa": true, "swe": true,
+	"syc": true, "syr": true, "tah": true, "tai": true, "tam": true, "tat": true,
+	"tel": true, "tem": true, "ter": true, "tet": true, "tgk": true, "tgl": true,
+	"tha": true, "tib": true, "tig": true, "tir": true, "tiv": true, "tkl": true,
+	"tlh": true, "tli": true, "tmh": true, "tog": true, "ton": true, "tpi": true,
+	"tsi": true, "tsn": true, "tso": true, "tuk": true, "tum": true, "tup": true,
+	"tur": true, "tut": true, "tvl": true, "twi": true, "tyv": true, "udm": true,
+	"uga": true, "uig": true, "ukr": true, "umb": true, "und": true, "urd": true,
+	"uzb": true, "vai": true, "ven": true, "vie": true, "vol": true, "vot": true,
+	"wak": true, "wal": true, "war": true, "was": true, "wel": true, "wen": true,
+	"wln": true, "wol": true, "xal": true, "xho": true, "yao": true, "yap": true,
+	"yid": true, "yor": true, "ypk": true, "zap": true, "zbl": true, "zen": true,
+	"zgh": true, "zha": true, "zho": true, "znd": true, "zul": true, "zun": true,
+	"zxx": true, "zza": true,
+}
+
+// twoLetterLanguages maps ISO 639-1 language codes to their ISO
+// 639-2 bibliographic equivalents.
+var twoLetterLanguages = map[string]string{
+	"aa": "aar", "ab": "abk", "ae": "ave", "af": "afr", "ak": "aka", "am": "amh",
+	"an": "arg", "ar": "ara", "as": "asm", "av": "ava", "ay": "aym", "az": "aze",
+	"ba": "bak", "be": "bel", "bg": "bul", "bh": "bih", "bi": "bis", "bm": "bam",
+	"bn": "ben", "bo": "tib", "br": "bre", "bs": "bos", "ca": "cat", "ce": "che",
+	"ch": "cha", "co": "cos", "cr": "cre", "cs": "cze", "cu": "chu", "cv": "chv",
+	"cy": "wel", "da": "dan", "de": "ger", "dv": "div", "dz": "dzo", "ee": "ewe",
+	"el": "gre", "en": "eng", "eo": "epo", "es": "spa", "et": "est", "eu": "baq",
+	"fa": "per", "ff": "ful", "fi": "fin", "fj": "fij", "fo": "fao", "fr": "fre",
+	"fy": "fry", "ga": "gle", "gd": "gla", "gl": "glg", "gn": "grn", "gu": "guj",
+	"gv": "glv", "ha": "hau", "he": "heb", "hi": "hin", "ho": "hmo", "hr": "hrv",
+	"ht": "hat", "hu": "hun", "hy": "arm", "hz": "her", "ia": "ina", "id": "ind",
+	"ie": "ile", "ig": "ibo", "ii": "iii", "ik": "ipk", "io": "ido", "is": "ice",
+	"it": "ita", "iu": "iku", "ja": "jpn", "jv": "jav", "ka": "geo", "kg": "kon",
+	"ki": "kik", "kj": "kua", "kk": "kaz", "kl": "kal", "km": "khm", "kn": "kan",
+	"ko": "kor", "kr": "kau", "ks": "kas", "ku": "kur", "kv": "kom", "kw": "cor",
+	"ky": "kir", "la": "lat", "lb": "ltz", "lg": "lug", "li": "lim", "ln": "lin",
+	"lo": "lao", "lt": "lit", "lu": "lub", "lv": "lav", "mg": "mlg", "mh": "mah",
+	"mi": "mao", "mk": "mac", "ml": "mal", "mn": "mon", "mr": "mar", "ms": "may",
+	"mt": "mlt", "my": "bur", "na": "nau", "nb": "nob", "nd": "nde", "ne": "nep",
+	"ng": "ndo", "nl": "dut", "nn": "nno", "no": "nor", "nr": "nbl", "nv": "nav",
+	"ny": "nya", "oc": "oci", "oj": "oji", "om": "orm", "or": "ori", "os": "oss",
+	"pa": "pan", "pi": "pli", "pl": "pol", "ps": "pus", "pt": "por", "qu": "que",
+	"rm": "roh", "rn": "run", "ro": "rum", "ru": "rus", "rw": "kin", "sa": "san",
+	"sc": "srd", "sd": "snd", "se": "sme", "sg": "sag", "si": "sin", "sk": "slo",
+	"sl": "slv", "sm": "smo", "sn": "sna", "so": "som", "sq": "alb", "sr": "srp",
+	"ss": "ssw", "st": "sot", "su": "sun", "sv": "swe", "sw": "swa", "ta": "tam",
+	"te": "tel", "tg": "tgk", "th": "tha", "ti": "tir", "tk": "tuk", "tl": "tgl",
+	"tn": "tsn", "to": "ton", "tr": "tur", "ts": "tso", "tt": "tat", "tw": "twi",
+	"ty": "tah", "ug": "uig", "uk": "ukr", "ur": "urd", "uz": "uzb", "ve": "ven",
+	"vi": "vie", "vo": "vol", "wa": "wln", "wo": "wol", "xh": "xho", "yi": "yid",
+	"yo": "yor", "za": "zha", "zh": "chi", "zu": "zul",
+}
+
+// ValidLanguage reports whether code is a lowercase ISO 639-2
+// language code. ID3 uses these codes in frames like COMM, USLT and
+// TLAN.
+func ValidLanguage(code string) bool {
+	return languages[code]
+}
+
+// NormalizeLanguage returns the ISO 639-2 code for code, which may be
+// an ISO 639-1 or ISO 639-2 code in any case. Codes it doesn't
+// recognise are returned unchanged.
+func NormalizeLanguage(code string) string {
+	lower := strings.ToLower(code)
+	if languages[lower] {
+		return lower
+	}
+	if lang, ok := twoLetterLanguages[lower]; ok {
+		return lang
+	}
+	return code
+}
@@ -0,0 +1,176 @@
+package id3
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNoAudio is returned by AudioLength if it couldn't find any MPEG
+// audio frames.
+var ErrNoAudio = errors.New("no MPEG audio frames found")
+
+// Bitrates in kbit/s, indexed by [version][layer][bitrate index].
+// Version 0 is MPEG-1, version 1 is MPEG-2 and MPEG-2.5. Layer 0 is
+// layer I.
+var mpegBitrates = [2][3][15]int{
+	{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	},
+	{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	},
+}
+
+// Sample rates in Hz, indexed by the version bits of the frame
+// header and the sample rate index.
+var mpegSampleRates = [4][3]int{
+	{11025, 12000, 8000},  // MPEG-2.5
+	{},                    // reserved
+	{22050, 24000, 16000}, // MPEG-2
+	{44100, 48000, 32000}, // MPEG-1
+}
+
+type mpegFrameHeader struct {
+	mpeg1      bool
+	layer      int // 1, 2 or 3
+	sampleRate int
+	samples    int // samples per frame
+	length     int // length of the frame in bytes, including the header
+	mono       bool
+}
+
+// parseMPEGFrameHeader parses the 4 byte header of an MPEG audio
+// frame. ok is false if b doesn't look like a valid header.
+func parseMPEGFrameHeader(b []byte) (h mpegFrameHeader, ok bool) {
+	if b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return h, false
+	}
+
+	version := int(b[1]>>3) & 3
+	layerBits := int(b[1]>>1) & 3
+	bitrateIndex := int(b[2] >> 4)
+	rateIndex := int(b[2]>>2) & 3
+	padding := int(b[2]>>1) & 1
+	if version == 1 || layerBits == 0 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return h, false
+	}
+
+	h.mpeg1 = version == 3
+	h.layer = 4 - layerBits
+	h.sampleRate = mpegSampleRates[version][rateIndex]
+	h.mono = b[3]>>6 == 3
+
+	v := 1
+	if h.mpeg1 {
+		v = 0
+	}
+	bitrate := mpegBitrates[v][h.layer-1][bitrateIndex] * 1000
+
+	switch {
+	case h.layer == 1:
+		h.samples = 384
+		h.length = (12*bitrate/h.sampleRate + padding) * 4
+	case h.layer == 3 && !h.mpeg1:
+		h.samples = 576
+		h.length = 72*bitrate/h.sampleRate + padding
+	default:
+		h.samples = 1152
+		h.length = 144*bitrate/h.sampleRate + padding
+	}
+
+	return h, h.length > 4
+}
+
+// xingFrames returns the number of frames stored in the Xing or Info
+// header of the first frame of a VBR file. frame must contain the
+// entire first frame.
+func xingFrames(h mpegFrameHeader, frame []byte) (int, bool) {
+	// The Xing header follows the side information
+	offset := 4 + 32
+	switch {
+	case h.mpeg1 && h.mono:
+		offset = 4 + 17
+	case !h.mpeg1 && !h.mono:
+		offset = 4 + 17
+	case !h.mpeg1 && h.mono:
+		offset = 4 + 9
+	}
+
+	if len(frame) < offset+12 {
+		return 0, false
+	}
+	frame = frame[offset:]
+	if !bytes.Equal(frame[0:4], []byte("Xing")) && !bytes.Equal(frame[0:4], []byte("Info")) {
+		return 0, false
+	}
+	if binary.BigEndian.Uint32(frame[4:8])&1 == 0 {
+		// The number of frames is optional
+		return 0, false
+	}
+
+	return int(binary.BigEndian.Uint32(frame[8:12])), true
+}
+
+// AudioLength determines the duration of the MPEG audio read from r,
+// which has to be positioned after the ID3 tag. That is the case after
+// a successful call to Decoder.Parse.
+//
+// If the first frame contains a Xing or Info header, the duration is
+// computed from the number of frames it declares. Otherwise, all
+// frames will be read and their durations summed, which works for
+// both CBR and VBR files.
+func AudioLength(r io.Reader) (time.Duration, error) {
+	br := bufio.NewReader(r)
+	var (
+		seconds float64
+		first   = true
+	)
+	for {
+		b, err := br.Peek(4)
+		if err == io.EOF || err == io.ErrUnexpectedEOF || (err == nil && len(b) < 4) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		h, ok := parseMPEGFrameHeader(b)
+		if !ok {
+			// Skip junk until we find the next frame
+			if _, err := br.Discard(1); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		if first {
+			first = false
+			frame, _ := br.Peek(h.length)
+			if n, ok := xingFrames(h, frame); ok {
+				return time.Duration(float64(n*h.samples) / float64(h.sampleRate) * float64(time.Second)), nil
+			}
+		}
+
+		seconds += float64(h.samples) / float64(h.sampleRate)
+		if _, err := br.Discard(h.length); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return 0, err
+		}
+	}
+
+	if first {
+		return 0, ErrNoAudio
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
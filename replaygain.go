@@ -0,0 +1,117 @@
+package id3
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ReplayGainTrack returns the track gain in dB and the track peak.
+// RVA2 frames are preferred over the TXXX frames written by many
+// tools. ok is false if neither representation exists.
+func (t *Tag) ReplayGainTrack() (gain float64, peak float64, ok bool) {
+	return t.replayGain("track")
+}
+
+// SetReplayGainTrack sets the track gain in dB and the track peak.
+// For maximum compatibility, both an RVA2 frame and the TXXX frames
+// are written.
+func (t *Tag) SetReplayGainTrack(gain float64, peak float64) {
+	t.setReplayGain("track", gain, peak)
+}
+
+// ReplayGainAlbum returns the album gain in dB and the album peak.
+// RVA2 frames are preferred over the TXXX frames written by many
+// tools. ok is false if neither representation exists.
+func (t *Tag) ReplayGainAlbum() (gain float64, peak float64, ok bool) {
+	return t.replayGain("album")
+}
+
+// SetReplayGainAlbum sets the album gain in dB and the album peak.
+// For maximum compatibility, both an RVA2 frame and the TXXX frames
+// are written.
+func (t *Tag) SetReplayGainAlbum(gain float64, peak float64) {
+	t.setReplayGain("album", gain, peak)
+}
+
+func (t *Tag) replayGain(kind string) (gain float64, peak float64, ok bool) {
+	for _, frame := range t.Frames["RVA2"] {
+		rva2, ok := frame.(RelativeVolumeAdjustmentFrame)
+		if !ok || !strings.EqualFold(rva2.Identification, kind) {
+			continue
+		}
+
+		for _, adj := range rva2.Adjustments {
+			if adj.Channel == MasterVolume {
+				return adj.Adjustment, adj.Peak, true
+			}
+		}
+	}
+
+	s, ok := t.userTextFrameFold("replaygain_" + kind + "_gain")
+	if !ok {
+		return 0, 0, false
+	}
+	gain, err := parseReplayGain(s)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	s, _ = t.userTextFrameFold("replaygain_" + kind + "_peak")
+	peak, _ = strconv.ParseFloat(strings.TrimSpace(s), 64)
+
+	return gain, peak, true
+}
+
+func (t *Tag) setReplayGain(kind string, gain float64, peak float64) {
+	frame := RelativeVolumeAdjustmentFrame{
+		FrameHeader:    FrameHeader{id: "RVA2"},
+		Identification: kind,
+		Adjustments: []VolumeAdjustment{{
+			Channel:    MasterVolume,
+			Adjustment: gain,
+			Peak:       peak,
+		}},
+	}
+
+	frames := t.Frames["RVA2"]
+	replaced := false
+	for i := range frames {
+		if old, ok := frames[i].(RelativeVolumeAdjustmentFrame); ok && strings.EqualFold(old.Identification, kind) {
+			frame.flags = frames[i].Header().flags & statusFlags
+			frames[i] = frame
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		t.Frames["RVA2"] = append(frames, frame)
+	}
+
+	t.setUserTextFrame("replaygain_"+kind+"_gain", fmt.Sprintf("%+.2f dB", gain))
+	t.setUserTextFrame("replaygain_"+kind+"_peak", fmt.Sprintf("%.6f", peak))
+}
+
+// userTextFrameFold is like getUserTextFrame but compares
+// descriptions case-insensitively, because tools disagree on the
+// case of the ReplayGain descriptions.
+func (t *Tag) userTextFrameFold(name string) (string, bool) {
+	for _, frame := range t.Frames["TXXX"] {
+		userFrame, ok := frame.(UserTextInformationFrame)
+		if ok && strings.EqualFold(userFrame.Description, name) {
+			return userFrame.Text, true
+		}
+	}
+
+	return "", false
+}
+
+// parseReplayGain parses gains of the form "+3.21 dB".
+func parseReplayGain(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(strings.ToLower(s), "db") {
+		s = strings.TrimSpace(s[:len(s)-2])
+	}
+
+	return strconv.ParseFloat(s, 64)
+}
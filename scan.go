@@ -0,0 +1,71 @@
+package id3
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync"
+)
+
+// ScanFile parses the tag at the beginning of the file at path. It
+// returns ErrNoTag if the file doesn't start with an ID3 tag.
+func ScanFile(path string) (*Tag, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	ok, err := Check(r)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNoTag
+	}
+
+	return NewDecoder(r).Parse()
+}
+
+// Result is the result of scanning a single file with Scan.
+type Result struct {
+	Path string
+	Tag  *Tag
+	Err  error
+}
+
+// Scan concurrently parses the files in paths with ScanFile, using up
+// to workers goroutines. It sends one result per path, in no
+// particular order, and closes the channel once all files have been
+// scanned.
+func Scan(paths []string, workers int) <-chan Result {
+	if workers < 1 {
+		workers = 1
+	}
+
+	in := make(chan string)
+	out := make(chan Result)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for path := range in {
+				tag, err := ScanFile(path)
+				out <- Result{Path: path, Tag: tag, Err: err}
+			}
+		}()
+	}
+
+	go func() {
+		for _, path := range paths {
+			in <- path
+		}
+		close(in)
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}